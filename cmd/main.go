@@ -1,10 +1,26 @@
 package main
 
 import (
+	"RTTServer/internal/api"
+	"RTTServer/internal/asn"
+	"RTTServer/internal/asnclass"
+	"RTTServer/internal/baseline"
 	"RTTServer/internal/cache"
+	"RTTServer/internal/client"
+	"RTTServer/internal/config"
 	"RTTServer/internal/echo"
+	"RTTServer/internal/enrich"
+	"RTTServer/internal/model"
+	"RTTServer/internal/predict"
+	"RTTServer/internal/rdns"
+	"RTTServer/internal/reputation"
+	"RTTServer/internal/retry"
+	"RTTServer/internal/surface"
 	"RTTServer/internal/tcp"
-	"encoding/json"
+	"RTTServer/internal/upstream"
+	"RTTServer/internal/vpnscore"
+	"RTTServer/internal/webhook"
+	"context"
 	"log"
 	"net"
 	"net/http"
@@ -19,29 +35,189 @@ const (
 )
 
 func main() {
+	cfg := config.Load()
+	var asnDB *asn.DB
+	if cfg.ASNDBPath != "" {
+		db, err := asn.Load(cfg.ASNDBPath)
+		if err != nil {
+			log.Fatalf("asn db %s: %v", cfg.ASNDBPath, err)
+		}
+		log.Printf("ASN db loaded: %d ranges", db.Len())
+		tcp.SetASNDB(db)
+		asnDB = db
+	}
+
+	upstreamCfg := upstream.Config{
+		Timeout:         cfg.UpstreamTimeout,
+		Retries:         cfg.UpstreamRetries,
+		RetryBase:       cfg.UpstreamRetryBase,
+		RetryMax:        cfg.UpstreamRetryMax,
+		BreakerFailures: cfg.UpstreamBreakerFailures,
+		BreakerCooldown: cfg.UpstreamBreakerCooldown,
+		Proxy:           cfg.UpstreamProxy,
+	}
+	if err := client.SetUpstreamConfig(upstreamCfg); err != nil {
+		log.Fatalf("upstream: %v", err)
+	}
+	if err := client.SetAuxConfig(upstreamCfg, cfg.PredictGeoRate/60); err != nil {
+		log.Fatalf("upstream: %v", err)
+	}
+	tcp.SetBaselineConfig(baseline.Config{
+		Outlier:            cfg.BaselineOutlier,
+		OutlierK:           cfg.BaselineOutlierK,
+		Weighted:           cfg.BaselineWeighted,
+		WeightScaleKm:      cfg.BaselineWeightScaleKm,
+		DistanceCorrection: cfg.BaselineDistanceCorrection,
+		FiberFactor:        cfg.FiberFactor,
+	})
+	tcp.SetFiberFactor(cfg.FiberFactor)
+
+	enrichers := enrich.NewRegistry()
+	if err := tcp.RegisterBuiltins(enrichers); err != nil {
+		log.Fatalf("enrichers: %v", err)
+	}
+	// свои enricher'ы регистрируются здесь, до сборки конвейера
+	if cfg.RDNSEnabled {
+		r := rdns.New(rdns.Config{Resolver: cfg.RDNSResolver, Timeout: cfg.RDNSTimeout, TTL: cfg.RDNSTTL,
+			MaxEntries: cfg.RDNSCacheMax})
+		if err := enrichers.Register(&rdns.Enricher{R: r}); err != nil {
+			log.Fatalf("enrichers: %v", err)
+		}
+	}
+	if len(cfg.ReputationLists) > 0 {
+		specs, err := reputation.ParseSpecs(cfg.ReputationLists)
+		if err != nil {
+			log.Fatalf("reputation: %v", err)
+		}
+		db, err := reputation.Open(specs)
+		if err != nil {
+			log.Fatalf("reputation: %v", err)
+		}
+		log.Printf("reputation lists loaded: %v", db.Counts())
+		go db.Watch(cfg.ReputationReloadEvery)
+		if err := enrichers.Register(&reputation.Enricher{DB: db}); err != nil {
+			log.Fatalf("enrichers: %v", err)
+		}
+	}
+	if cfg.ASNClassesPath != "" {
+		classes, err := asnclass.Load(cfg.ASNClassesPath)
+		if err != nil {
+			log.Fatalf("asn classes %s: %v", cfg.ASNClassesPath, err)
+		}
+		log.Printf("ASN classes loaded: %d", classes.Len())
+		if err := enrichers.Register(&asnclass.Enricher{C: classes}); err != nil {
+			log.Fatalf("enrichers: %v", err)
+		}
+	}
+	vpnCfg := vpnscore.DefaultConfig()
+	vpnCfg.FiberFactor = cfg.FiberFactor
+	vpnCfg.BaselineRatio = cfg.VPNBaselineRatio
+	vpnCfg.BaselineExcessMs = cfg.VPNBaselineExcessMs
+	vpnCfg.SuspiciousScore = cfg.VPNSuspiciousScore
+	vpnCfg.VPNScore = cfg.VPNScore
+	if err := enrichers.Register(&vpnscore.Enricher{Cfg: vpnCfg}); err != nil {
+		log.Fatalf("enrichers: %v", err)
+	}
+	pipeline, err := enrichers.Pipeline(cfg.Enrichers, cfg.EnrichTimeout)
+	if err != nil {
+		log.Fatalf("enrichers: %v", err)
+	}
+	log.Printf("enrichers: %s", strings.Join(pipeline.Names(), ", "))
+	tcp.SetPipeline(pipeline)
+
 	store := cache.New()
 	go store.Janitor(cleanEvery)
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/rtt", func(w http.ResponseWriter, r *http.Request) {
-		ip := strings.TrimSpace(r.URL.Query().Get("ip"))
-		if ip == "" {
-			http.Error(w, "use /rtt?ip=1.2.3.4 or /rtt/all", http.StatusBadRequest)
-			return
+	raws := cache.NewRawStore(cache.RawPolicy{
+		MaxAge:     cfg.RawMaxAge,
+		MaxEntries: cfg.RawMaxEntries,
+		Compress:   cfg.RawCompress,
+	})
+	go raws.Janitor(cleanEvery)
+	tcp.SetRawStore(raws)
+
+	retries, err := retry.Open(cfg.RetryQueuePath, retry.Policy{
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+		MaxAttempts: cfg.RetryMaxAttempts,
+	})
+	if err != nil {
+		log.Fatalf("retry queue %s: %v", cfg.RetryQueuePath, err)
+	}
+	tcp.SetRetryQueue(retries)
+	go tcp.RunRetries(store, cfg.RetryEvery)
+
+	var hooks *webhook.Dispatcher
+	if cfg.WebhooksFile != "" {
+		subs, err := webhook.Load(cfg.WebhooksFile)
+		if err != nil {
+			log.Fatalf("webhooks: %v", err)
 		}
-		rec, ok := store.Get(ip)
-		if !ok {
-			http.Error(w, "not found or expired", http.StatusNotFound)
-			return
+		hooks, err = webhook.New(subs, upstream.Config{
+			Timeout:         cfg.WebhookTimeout,
+			Retries:         cfg.WebhookRetries,
+			RetryBase:       time.Second,
+			RetryMax:        cfg.WebhookRetryMax,
+			BreakerFailures: cfg.UpstreamBreakerFailures,
+			BreakerCooldown: cfg.UpstreamBreakerCooldown,
+			Proxy:           cfg.UpstreamProxy,
+		})
+		if err != nil {
+			log.Fatalf("webhooks: %v", err)
 		}
-		writeJSON(w, rec)
-	})
-	mux.HandleFunc("/rtt/all", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, store.AllFresh())
+		log.Printf("webhooks: %d subscriptions", hooks.Len())
+		go hooks.Run(store)
+	}
+
+	surf, err := surface.New(surface.Config{
+		Power:          2,
+		RadiusKm:       cfg.EstimateRadiusKm,
+		PriorKm:        cfg.EstimatePriorKm,
+		Inflation:      cfg.EstimateInflation,
+		OverheadMs:     cfg.EstimateOverheadMs,
+		FiberFactor:    cfg.FiberFactor,
+		BaselineWeight: 0.5,
+		BinDeg:         0.5,
+		CellDeg:        cfg.EstimateCellDeg,
 	})
+	if err != nil {
+		log.Fatalf("estimate: %v", err)
+	}
+	go surf.Run(store, cfg.EstimateEvery)
+
+	// геолокация для прогноза — через отдельный клиент с лимитом, не через
+	// клиент обогащения замеров; при отказе прогноз обходится без неё
+	var predictGeo predict.GeoFunc
+	if cfg.PredictGeoRate > 0 {
+		predictGeo = func(ctx context.Context, ip string) (*model.Geo, error) {
+			country, region, city, lat, lon, err := client.ClientIPAPIAux(ctx, ip)
+			if err != nil {
+				return nil, err
+			}
+			return &model.Geo{Country: country, Region: region, City: city, Latitude: lat, Longitude: lon}, nil
+		}
+	}
+	predictor := predict.New(predict.Config{
+		PriorASN:    cfg.PredictPriorASN,
+		PriorPrefix: cfg.PredictPriorPrefix,
+		FiberFactor: cfg.FiberFactor,
+		GeoTTL:      time.Hour,
+	}, store, asnDB, predictGeo)
+	go predictor.Run(cfg.PredictEvery)
+
+	srv := &api.Server{
+		Store:     store,
+		Raws:      raws,
+		Retries:   retries,
+		Webhooks:  hooks,
+		BatchMax:  cfg.BatchMax,
+		Surface:   surf,
+		Predictor: predictor,
+		Enrichers: pipeline.Names(),
+	}
 	go func() {
 		log.Printf("HTTP listening on %s", httpListenAddr)
-		if err := http.ListenAndServe(httpListenAddr, logRequest(mux)); err != nil {
+		if err := http.ListenAndServe(httpListenAddr, srv.Handler()); err != nil {
 			log.Fatalf("http serve: %v", err)
 		}
 	}()
@@ -66,18 +242,3 @@ func main() {
 		go tcp.HandleConn(c, store)
 	}
 }
-
-func writeJSON(w http.ResponseWriter, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	_ = enc.Encode(v)
-}
-
-func logRequest(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
-	})
-}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// общие флаги команд, работающих с HTTP API
+type apiFlags struct {
+	base    string
+	json    bool
+	timeout time.Duration
+}
+
+func (f *apiFlags) register(fs *flag.FlagSet) {
+	def := os.Getenv("RTTCTL_API")
+	if def == "" {
+		def = "http://localhost:9080"
+	}
+	fs.StringVar(&f.base, "api", def, "HTTP API base URL (env RTTCTL_API)")
+	fs.BoolVar(&f.json, "json", false, "print raw JSON instead of a table")
+	fs.DurationVar(&f.timeout, "timeout", 10*time.Second, "HTTP timeout")
+}
+
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// get запрашивает /v1+path; ошибка API превращается в error с её сообщением
+func (f *apiFlags) get(path string, q url.Values) ([]byte, error) {
+	body, _, err := f.do(path, q)
+	return body, err
+}
+
+// page запрашивает страницу /rtt/all и возвращает курсор следующей
+func (f *apiFlags) page(q url.Values) ([]byte, string, error) {
+	body, h, err := f.do("/rtt/all", q)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, h.Get("X-Next-Cursor"), nil
+}
+
+func (f *apiFlags) do(path string, q url.Values) ([]byte, http.Header, error) {
+	u := strings.TrimRight(f.base, "/") + "/v1" + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	c := &http.Client{Timeout: f.timeout}
+	resp, err := c.Get(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var ae apiError
+		if json.Unmarshal(body, &ae) == nil && ae.Error.Message != "" {
+			return nil, nil, fmt.Errorf("%s: %s (%s)", path, ae.Error.Message, ae.Error.Code)
+		}
+		return nil, nil, fmt.Errorf("%s: http %d", path, resp.StatusCode)
+	}
+	return body, resp.Header, nil
+}
+
+func (f *apiFlags) getJSON(path string, q url.Values, v any) error {
+	body, err := f.get(path, q)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// printRaw печатает тело ответа как есть — для -json
+func printRaw(body []byte) {
+	os.Stdout.Write(body)
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		fmt.Println()
+	}
+}
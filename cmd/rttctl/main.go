@@ -0,0 +1,52 @@
+// rttctl — клиент RTTServer для эксплуатации: замер через рукопожатие
+// и запросы к HTTP API без ручных nc и curl.
+//
+//	rttctl measure [-addr host:9000] [-n 10] [-interval 1s]
+//	rttctl get 1.2.3.4
+//	rttctl list -country US -sort -rtt -limit 20
+//	rttctl history 1.2.3.4
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: rttctl <command> [flags]
+
+commands:
+  measure   connect to the measuring port, print client and server RTT
+  get       show the record for an IP (/v1/rtt)
+  list      list records with filters (/v1/rtt/all)
+  history   show measurement history for an IP (/v1/rtt/history)
+
+Run "rttctl <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "measure":
+		err = runMeasure(args)
+	case "get":
+		err = runGet(args)
+	case "list":
+		err = runList(args)
+	case "history":
+		err = runHistory(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "rttctl: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rttctl: %v\n", err)
+		os.Exit(1)
+	}
+}
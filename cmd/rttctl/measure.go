@@ -0,0 +1,260 @@
+package main
+
+import (
+	"RTTServer/internal/cache"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// порт, на котором сервер меряет сразу; echo-порты уходят в замер
+// только если клиент молчит дольше firstByteTimeout (2s)
+const measurePort = "9000"
+
+const (
+	echoByte    = 0xAA
+	echoHoldDef = 2500 * time.Millisecond
+)
+
+type attempt struct {
+	connect   time.Duration
+	app       time.Duration
+	server    *cache.Sample
+	err       error
+	serverErr error
+}
+
+func runMeasure(args []string) error {
+	fs := flag.NewFlagSet("measure", flag.ExitOnError)
+	var api apiFlags
+	api.register(fs)
+	addr := fs.String("addr", "localhost:"+measurePort, "measuring port host:port (9000 or an echo port)")
+	n := fs.Int("n", 1, "number of measurements")
+	interval := fs.Duration("interval", time.Second, "pause between measurements")
+	hold := fs.Duration("hold", -1, "silence before the first byte; default 0 for :9000, 2.5s for echo ports")
+	echo := fs.Bool("echo", false, "echo mode: send 0xAA, client-side RTT only, nothing is recorded")
+	wait := fs.Duration("wait", 5*time.Second, "how long to wait for the server-side sample")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *n < 1 {
+		return errors.New("-n must be at least 1")
+	}
+	_, port, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return fmt.Errorf("-addr: %w", err)
+	}
+	if *hold < 0 {
+		*hold = 0
+		if port != measurePort && !*echo {
+			*hold = echoHoldDef
+		}
+	}
+
+	// серверный замер ищем в истории по адресу, который видит сервер
+	var ip string
+	if !*echo {
+		var who struct {
+			IP string `json:"ip"`
+		}
+		if err := api.getJSON("/whoami", nil, &who); err != nil {
+			return fmt.Errorf("whoami: %w", err)
+		}
+		ip = who.IP
+	}
+
+	var res []attempt
+	if !api.json {
+		fmt.Printf(rowFmt, "SEQ", "CONNECT_MS", "APP_MS", "SERVER_RTT_MS", "SERVER_RTTVAR_MS")
+	}
+	for i := 0; i < *n; i++ {
+		if i > 0 {
+			time.Sleep(*interval)
+		}
+		var last time.Time
+		if !*echo {
+			last = lastSample(&api, ip)
+		}
+		a := handshake(*addr, *hold, *echo)
+		if a.err == nil && !*echo {
+			a.server, a.serverErr = waitSample(&api, ip, last, *wait)
+		}
+		res = append(res, a)
+		if !api.json {
+			printAttempt(i+1, a)
+		}
+	}
+
+	failed := 0
+	for _, a := range res {
+		if a.err != nil {
+			failed++
+		}
+	}
+	if api.json {
+		if err := printMeasureJSON(ip, res); err != nil {
+			return err
+		}
+	} else if *n > 1 {
+		fmt.Println()
+		var connect, app, server []float64
+		lost := 0
+		for _, a := range res {
+			if a.err != nil {
+				lost++
+				continue
+			}
+			connect = append(connect, ms(a.connect))
+			app = append(app, ms(a.app))
+			if a.server != nil {
+				server = append(server, a.server.RTTms)
+			}
+		}
+		ss := []series{{"connect_ms", connect}, {"app_ms", app}}
+		if !*echo {
+			ss = append(ss, series{"server_rtt_ms", server})
+		}
+		printStats(ss)
+		fmt.Printf("\n%d sent, %d failed, %.1f%% loss\n", *n, lost, 100*float64(lost)/float64(*n))
+	}
+	if failed == *n {
+		return errors.New("all measurements failed")
+	}
+	return nil
+}
+
+// handshake — одно соединение: время connect() и время ответа на первый байт
+func handshake(addr string, hold time.Duration, echo bool) attempt {
+	var a attempt
+	t0 := time.Now()
+	c, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		a.err = err
+		return a
+	}
+	defer c.Close()
+	a.connect = time.Since(t0)
+	if tc, ok := c.(*net.TCPConn); ok {
+		_ = tc.SetNoDelay(true)
+	}
+	if hold > 0 {
+		time.Sleep(hold)
+	}
+	b := []byte{1}
+	if echo {
+		b[0] = echoByte
+	}
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	t1 := time.Now()
+	if _, err := c.Write(b); err != nil {
+		a.err = err
+		return a
+	}
+	if _, err := c.Read(b); err != nil {
+		a.err = fmt.Errorf("read reply: %w", err)
+		return a
+	}
+	a.app = time.Since(t1)
+	return a
+}
+
+func history(api *apiFlags, ip string) ([]cache.Sample, error) {
+	var h struct {
+		Samples []cache.Sample `json:"samples"`
+	}
+	err := api.getJSON("/rtt/history", url.Values{"ip": {ip}}, &h)
+	return h.Samples, err
+}
+
+// lastSample — время последнего серверного замера; часы клиента и сервера
+// не сравниваем, новый замер ищем как более поздний сэмпл
+func lastSample(api *apiFlags, ip string) time.Time {
+	h, _ := history(api, ip)
+	if len(h) == 0 {
+		return time.Time{}
+	}
+	return h[len(h)-1].At
+}
+
+// waitSample ждёт в /rtt/history сэмпл новее last
+func waitSample(api *apiFlags, ip string, last time.Time, wait time.Duration) (*cache.Sample, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		h, err := history(api, ip)
+		if n := len(h); err == nil && n > 0 && h[n-1].At.After(last) {
+			return &h[n-1], nil
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = errors.New("no server-side sample")
+			}
+			return nil, err
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// строки печатаются по мере замеров, поэтому ширина колонок фиксирована
+const rowFmt = "%-5s %-11s %-9s %-14s %s\n"
+
+func printAttempt(seq int, a attempt) {
+	if a.err != nil {
+		fmt.Printf("%-5d error: %v\n", seq, a.err)
+		return
+	}
+	srv, srvVar := "-", "-"
+	if a.server != nil {
+		srv, srvVar = fmt.Sprintf("%.3f", a.server.RTTms), fmt.Sprintf("%.3f", a.server.RTTVarMs)
+	} else if a.serverErr != nil {
+		srv = "error: " + a.serverErr.Error()
+	}
+	app := "-"
+	if a.app > 0 {
+		app = fmt.Sprintf("%.3f", ms(a.app))
+	}
+	fmt.Printf(rowFmt, strconv.Itoa(seq), fmt.Sprintf("%.3f", ms(a.connect)), app, srv, srvVar)
+}
+
+func printMeasureJSON(ip string, res []attempt) error {
+	type row struct {
+		ConnectMs      float64  `json:"connect_ms,omitempty"`
+		AppMs          float64  `json:"app_ms,omitempty"`
+		ServerRTTms    *float64 `json:"server_rtt_ms,omitempty"`
+		ServerRTTVarMs *float64 `json:"server_rttvar_ms,omitempty"`
+		Error          string   `json:"error,omitempty"`
+	}
+	out := struct {
+		IP       string `json:"ip,omitempty"`
+		Attempts []row  `json:"attempts"`
+	}{IP: ip, Attempts: make([]row, 0, len(res))}
+	for _, a := range res {
+		r := row{ConnectMs: ms(a.connect), AppMs: ms(a.app)}
+		switch {
+		case a.err != nil:
+			r.Error = a.err.Error()
+		case a.server != nil:
+			r.ServerRTTms, r.ServerRTTVarMs = &a.server.RTTms, &a.server.RTTVarMs
+		case a.serverErr != nil:
+			r.Error = a.serverErr.Error()
+		}
+		out.Attempts = append(out.Attempts, r)
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	printRaw(b)
+	return nil
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// parseIPArgs разбирает флаги и один позиционный ip в любом порядке
+func parseIPArgs(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() == 0 {
+		return "", errors.New("ip is required")
+	}
+	ip := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", err
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return ip, nil
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+func num(v float64, prec int) string {
+	if v == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+func since(t time.Time) string {
+	d := time.Since(t).Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	return d.String() + " ago"
+}
+
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	var api apiFlags
+	api.register(fs)
+	ip, err := parseIPArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	body, err := api.get("/rtt", url.Values{"ip": {ip}})
+	if err != nil {
+		return err
+	}
+	if api.json {
+		printRaw(body)
+		return nil
+	}
+	var rec model.RTTRecord
+	if err := json.Unmarshal(body, &rec); err != nil {
+		return err
+	}
+	printRecord(rec)
+	return nil
+}
+
+func printRecord(rec model.RTTRecord) {
+	tw := newTable()
+	row := func(k, v string) {
+		if v != "" && v != "-" {
+			fmt.Fprintf(tw, "%s\t%s\n", k, v)
+		}
+	}
+	row("ip", rec.IP)
+	row("rtt", num(rec.RTT_ms, 3)+" ms")
+	row("rttvar", num(rec.RTTVar_ms, 3)+" ms")
+	row("tcpi_rtt_us", strconv.FormatUint(uint64(rec.TCPI_RTT_us), 10))
+	row("tcpi_rttvar_us", strconv.FormatUint(uint64(rec.TCPI_VAR_us), 10))
+	row("listener_port", strconv.Itoa(rec.ListenerPort))
+	if rec.Geo != nil {
+		row("location", strings.Join(nonEmpty(rec.Geo.City, rec.Geo.Region, rec.Geo.Country), ", "))
+		row("coordinates", fmt.Sprintf("%.3f, %.3f", rec.Geo.Latitude, rec.Geo.Longitude))
+	}
+	if rec.DistanceToServer != nil {
+		row("distance", num(*rec.DistanceToServer, 0)+" km")
+	}
+	if rec.ASN != 0 {
+		row("asn", fmt.Sprintf("AS%d %s", rec.ASN, rec.ASOrg))
+	}
+	row("network_type", rec.NetworkType)
+	row("ptr", rec.PTR)
+	if rec.GlobalpingRTT > 0 {
+		row("baseline", fmt.Sprintf("%.3f ms (confidence %.2f, %d probes)", rec.GlobalpingRTT, rec.GlobalpingConfidence, len(rec.InfoProbes)))
+	}
+	if rec.VPN != nil {
+		row("vpn", fmt.Sprintf("%s (score %.2f) %s", rec.VPN.Verdict, rec.VPN.Score, strings.Join(rec.VPN.Reasons, "; ")))
+	}
+	row("reputation", strings.Join(rec.Reputation, ", "))
+	row("updated", since(rec.UpdatedAt))
+	tw.Flush()
+}
+
+func nonEmpty(vals ...string) []string {
+	var out []string
+	for _, v := range vals {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var api apiFlags
+	api.register(fs)
+	country := fs.String("country", "", "comma-separated countries")
+	asn := fs.String("asn", "", "comma-separated ASNs")
+	port := fs.String("port", "", "comma-separated listener ports")
+	networkType := fs.String("network-type", "", "comma-separated network types")
+	reputation := fs.String("reputation", "", "comma-separated reputation lists")
+	minRTT := fs.String("min-rtt", "", "minimum RTT, ms")
+	maxRTT := fs.String("max-rtt", "", "maximum RTT, ms")
+	updatedSince := fs.String("since", "", "updated since: RFC3339 or duration like 15m")
+	sortBy := fs.String("sort", "-updated_at", "sort field, prefix with - for descending")
+	limit := fs.Int("limit", 50, "records per page")
+	all := fs.Bool("all", false, "follow cursors and fetch every page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	for k, v := range map[string]string{
+		"country": *country, "asn": *asn, "port": *port, "network_type": *networkType,
+		"reputation": *reputation, "min_rtt_ms": *minRTT, "max_rtt_ms": *maxRTT,
+		"updated_since": *updatedSince, "sort": *sortBy,
+	} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	q.Set("limit", strconv.Itoa(*limit))
+
+	var recs []model.RTTRecord
+	for {
+		var page []model.RTTRecord
+		body, next, err := api.page(q)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		recs = append(recs, page...)
+		if !*all || next == "" {
+			break
+		}
+		q.Set("cursor", next)
+	}
+	if api.json {
+		b, _ := json.MarshalIndent(recs, "", "  ")
+		printRaw(b)
+		return nil
+	}
+
+	tw := newTable()
+	fmt.Fprintln(tw, "IP\tCOUNTRY\tCITY\tASN\tNETWORK\tRTT_MS\tRTTVAR_MS\tBASELINE_MS\tDIST_KM\tVPN\tUPDATED")
+	for _, r := range recs {
+		var country, city, asn, vpn, dist string
+		if r.Geo != nil {
+			country, city = r.Geo.Country, r.Geo.City
+		}
+		if r.ASN != 0 {
+			asn = "AS" + strconv.Itoa(r.ASN)
+		}
+		if r.VPN != nil {
+			vpn = r.VPN.Verdict
+		}
+		if r.DistanceToServer != nil {
+			dist = num(*r.DistanceToServer, 0)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.IP, dash(country), dash(city), dash(asn), dash(r.NetworkType),
+			num(r.RTT_ms, 3), num(r.RTTVar_ms, 3), num(r.GlobalpingRTT, 3), dash(dist), dash(vpn), since(r.UpdatedAt))
+	}
+	tw.Flush()
+	fmt.Fprintf(os.Stderr, "%d records\n", len(recs))
+	return nil
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	var api apiFlags
+	api.register(fs)
+	ip, err := parseIPArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	body, err := api.get("/rtt/history", url.Values{"ip": {ip}})
+	if err != nil {
+		return err
+	}
+	if api.json {
+		printRaw(body)
+		return nil
+	}
+	var h struct {
+		IP      string         `json:"ip"`
+		Samples []cache.Sample `json:"samples"`
+	}
+	if err := json.Unmarshal(body, &h); err != nil {
+		return err
+	}
+	tw := newTable()
+	fmt.Fprintln(tw, "AT\tRTT_MS\tRTTVAR_MS\tPORT\tBASELINE_MS")
+	rtts := make([]float64, 0, len(h.Samples))
+	for _, s := range h.Samples {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", s.At.Local().Format(time.DateTime),
+			num(s.RTTms, 3), num(s.RTTVarMs, 3), s.ListenerPort, num(s.GlobalpingRTT, 3))
+		rtts = append(rtts, s.RTTms)
+	}
+	tw.Flush()
+	fmt.Println()
+	printStats([]series{{"rtt_ms", rtts}})
+	return nil
+}
+
+// series — набор значений одной метрики для сводки
+type series struct {
+	name string
+	xs   []float64
+}
+
+func printStats(ss []series) {
+	tw := newTable()
+	fmt.Fprintln(tw, "METRIC\tN\tMIN\tMEDIAN\tMEAN\tP95\tMAX\tSTDDEV")
+	for _, s := range ss {
+		if len(s.xs) == 0 {
+			fmt.Fprintf(tw, "%s\t0\t-\t-\t-\t-\t-\t-\n", s.name)
+			continue
+		}
+		mn, mx, sum := s.xs[0], s.xs[0], 0.0
+		for _, x := range s.xs {
+			mn, mx, sum = min(mn, x), max(mx, x), sum+x
+		}
+		mean := sum / float64(len(s.xs))
+		var sq float64
+		for _, x := range s.xs {
+			sq += (x - mean) * (x - mean)
+		}
+		sd := 0.0
+		if len(s.xs) > 1 {
+			sd = math.Sqrt(sq / float64(len(s.xs)-1))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\n", s.name, len(s.xs),
+			mn, utils.Median(s.xs), mean, utils.Quantile(s.xs, 0.95), mx, sd)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"RTTServer/internal/cache"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// getPrefix: cidr может быть и одиночным ip — тогда ответ по его /24 (/48)
+func (s *Server) getPrefix(w http.ResponseWriter, r *http.Request) {
+	v := strings.TrimSpace(r.URL.Query().Get("cidr"))
+	if v == "" {
+		writeError(w, http.StatusBadRequest, "missing_parameter", "use /v1/rtt/prefix?cidr=1.2.3.0/24",
+			map[string]any{"parameter": "cidr"})
+		return
+	}
+	var p netip.Prefix
+	if strings.Contains(v, "/") {
+		var err error
+		if p, err = netip.ParsePrefix(v); err != nil {
+			writeParamError(w, &paramError{"cidr", v, "expected CIDR or IP"})
+			return
+		}
+	} else {
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			writeParamError(w, &paramError{"cidr", v, "expected CIDR or IP"})
+			return
+		}
+		p = netip.PrefixFrom(a, a.BitLen())
+	}
+	agg, ok, err := s.Store.PrefixAggregate(p)
+	if errors.Is(err, cache.ErrPrefixTooWide) {
+		writeParamError(w, &paramError{"cidr", v, fmt.Sprintf("prefix wider than /%d (IPv4) or /%d (IPv6)",
+			cache.PrefixBits4-cache.MaxAggregateSpan, cache.PrefixBits6-cache.MaxAggregateSpan)})
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "no fresh records in this network",
+			map[string]any{"cidr": v, "prefix": agg.Prefix})
+		return
+	}
+	writeJSON(w, agg)
+}
+
+func (s *Server) getASN(w http.ResponseWriter, r *http.Request) {
+	v := r.PathValue("asn")
+	asn, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(v), "AS"))
+	if err != nil || asn <= 0 {
+		writeParamError(w, &paramError{"asn", v, "expected AS number, e.g. 13335 or AS13335"})
+		return
+	}
+	agg, ok := s.Store.ASNAggregate(asn)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "no fresh records for this ASN",
+			map[string]any{"asn": asn})
+		return
+	}
+	writeJSON(w, agg)
+}
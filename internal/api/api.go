@@ -0,0 +1,109 @@
+package api
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/predict"
+	"RTTServer/internal/retry"
+	"RTTServer/internal/surface"
+	"RTTServer/internal/ui"
+	"RTTServer/internal/webhook"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Server struct {
+	Store     *cache.Store
+	Raws      *cache.RawStore
+	Retries   *retry.Queue
+	Webhooks  *webhook.Dispatcher
+	BatchMax  int // сколько элементов принимает /rtt/batch, 0 — defaultBatchMax
+	Surface   *surface.Surface
+	Predictor *predict.Predictor
+	Enrichers []string
+}
+
+// Handler отдаёт /v1 API; старые пути без префикса остаются алиасами
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	s.route(mux, http.MethodGet, "/rtt", s.getRTT)
+	s.route(mux, http.MethodGet, "/rtt/all", s.getAll)
+	s.route(mux, http.MethodGet, "/rtt/history", s.getHistory)
+	s.route(mux, http.MethodPost, "/rtt/batch", s.postBatch)
+	s.route(mux, http.MethodGet, "/rtt/group", s.getGroup)
+	s.route(mux, http.MethodGet, "/rtt/prefix", s.getPrefix)
+	s.route(mux, http.MethodGet, "/rtt/asn/{asn}", s.getASN)
+	s.route(mux, http.MethodGet, "/rtt/predict", s.getPredict)
+	s.route(mux, http.MethodGet, "/rtt/predict/model", s.getPredictModel)
+	s.route(mux, http.MethodGet, "/rtt/geojson", s.getGeoJSON)
+	s.route(mux, http.MethodGet, "/rtt/heatmap", s.getHeatmap)
+	s.route(mux, http.MethodGet, "/rtt/stream", s.getStream)
+	s.route(mux, http.MethodGet, "/estimate", s.getEstimate)
+	s.route(mux, http.MethodGet, "/estimate/grid", s.getEstimateGrid)
+	s.route(mux, http.MethodGet, "/stats/countries", s.getCountryStats)
+	s.route(mux, http.MethodGet, "/stats/regions", s.getRegionStats)
+	s.route(mux, http.MethodGet, "/health", s.getHealth)
+	s.route(mux, http.MethodGet, "/whoami", s.getWhoami)
+	s.route(mux, http.MethodGet, "/enrichment/retries", s.getRetries)
+	s.route(mux, http.MethodGet, "/globalping/measurements/{id}/raw", s.getRaw)
+	s.route(mux, http.MethodGet, "/webhooks", s.getWebhooks)
+	s.route(mux, http.MethodGet, "/webhooks/deliveries", s.getDeliveries)
+	mux.HandleFunc("/v1/openapi.json", methods(serveOpenAPI, http.MethodGet))
+	mux.HandleFunc("/ui/", methods(http.StripPrefix("/ui/", ui.Handler()).ServeHTTP, http.MethodGet))
+	mux.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", "no such endpoint", map[string]any{"path": r.URL.Path})
+	})
+	return logRequest(mux)
+}
+
+func (s *Server) route(mux *http.ServeMux, method, path string, h http.HandlerFunc) {
+	mux.HandleFunc("/v1"+path, methods(h, method))
+	mux.HandleFunc(path, methods(h, method))
+}
+
+// methods отвечает 405 с JSON-ошибкой на прочие методы; HEAD разрешён вместе с GET
+func methods(h http.HandlerFunc, allowed ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range allowed {
+			if r.Method == m || (m == http.MethodGet && r.Method == http.MethodHead) {
+				h(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed",
+			r.Method+" is not allowed", map[string]any{"allowed": allowed})
+	}
+}
+
+type apiError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string, details map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error apiError `json:"error"`
+	}{apiError{Code: code, Message: msg, Details: details}})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+	})
+}
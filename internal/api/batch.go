@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	defaultBatchMax = 1000
+	batchBodyLimit  = 1 << 20
+)
+
+type batchRequest struct {
+	IPs []string `json:"ips"`
+}
+
+// postBatch принимает {"ips": [...]} — адреса и подсети вперемешку
+func (s *Server) postBatch(w http.ResponseWriter, r *http.Request) {
+	limit := s.BatchMax
+	if limit <= 0 {
+		limit = defaultBatchMax
+	}
+	var req batchRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, batchBodyLimit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "too_large", "request body is too large",
+				map[string]any{"limit_bytes": batchBodyLimit})
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid_body", "expected {\"ips\": [\"1.2.3.4\", \"10.0.0.0/24\"]}",
+			map[string]any{"error": err.Error()})
+		return
+	}
+	if len(req.IPs) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_body", "ips is empty", nil)
+		return
+	}
+	if len(req.IPs) > limit {
+		writeError(w, http.StatusRequestEntityTooLarge, "too_large",
+			fmt.Sprintf("at most %d ips per request", limit), map[string]any{"limit": limit, "got": len(req.IPs)})
+		return
+	}
+
+	var ips []netip.Addr
+	var prefixes []netip.Prefix
+	var invalid []string
+	for _, v := range req.IPs {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			p, err := netip.ParsePrefix(v)
+			if err != nil {
+				invalid = append(invalid, v)
+				continue
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			invalid = append(invalid, v)
+			continue
+		}
+		ips = append(ips, a.Unmap())
+	}
+	if len(invalid) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "some entries are not IPs or CIDRs",
+			map[string]any{"parameter": "ips", "invalid": invalid})
+		return
+	}
+	writeJSON(w, s.Store.Batch(ips, prefixes))
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"RTTServer/internal/surface"
+	"net/http"
+	"time"
+)
+
+type estimateResponse struct {
+	surface.Estimate
+	Samples int       `json:"samples"`
+	BuiltAt time.Time `json:"built_at"`
+}
+
+type estimateGrid struct {
+	CellDeg float64            `json:"cell_deg"`
+	Samples int                `json:"samples"`
+	BuiltAt time.Time          `json:"built_at"`
+	Cells   []surface.Estimate `json:"cells"`
+}
+
+func (s *Server) surfaceModel(w http.ResponseWriter) (*surface.Model, bool) {
+	if s.Surface == nil {
+		writeError(w, http.StatusServiceUnavailable, "unavailable", "latency surface is not enabled", nil)
+		return nil, false
+	}
+	return s.Surface.Current(), true
+}
+
+func (s *Server) getEstimate(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	lat, err := parseFloat(vals, "lat")
+	if err == nil && (lat == nil || *lat < -90 || *lat > 90) {
+		err = &paramError{"lat", vals.Get("lat"), "expected latitude in -90..90"}
+	}
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	lon, err := parseFloat(vals, "lon")
+	if err == nil && (lon == nil || *lon < -180 || *lon > 180) {
+		err = &paramError{"lon", vals.Get("lon"), "expected longitude in -180..180"}
+	}
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	m, ok := s.surfaceModel(w)
+	if !ok {
+		return
+	}
+	writeJSON(w, estimateResponse{Estimate: m.Estimate(*lat, *lon), Samples: m.Samples, BuiltAt: m.BuiltAt})
+}
+
+// getEstimateGrid отдаёт сетку последней сборки; пустые ячейки (без наблюдений
+// в радиусе) не включаются, min_confidence отсекает и слабые
+func (s *Server) getEstimateGrid(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	minConf, err := parseFloat(vals, "min_confidence")
+	if err == nil && minConf != nil && (*minConf < 0 || *minConf > 1) {
+		err = &paramError{"min_confidence", vals.Get("min_confidence"), "expected number in 0..1"}
+	}
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	m, ok := s.surfaceModel(w)
+	if !ok {
+		return
+	}
+	out := estimateGrid{CellDeg: m.CellDeg(), Samples: m.Samples, BuiltAt: m.BuiltAt, Cells: []surface.Estimate{}}
+	for _, e := range m.Grid {
+		if minConf == nil || e.Confidence >= *minConf {
+			out.Cells = append(out.Cells, e)
+		}
+	}
+	writeJSON(w, out)
+}
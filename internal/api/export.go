@@ -0,0 +1,286 @@
+package api
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// exportFormat: параметр format= важнее заголовка Accept
+func exportFormat(r *http.Request) (string, bool) {
+	if f := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))); f != "" {
+		switch f {
+		case formatJSON, formatNDJSON, formatCSV:
+			return f, true
+		case "jsonl":
+			return formatNDJSON, true
+		}
+		return "", false
+	}
+	// побеждает тип с наибольшим q, при равенстве — раньше в заголовке;
+	// q=0 — тип запрещён
+	best, bestQ := formatJSON, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		var f string
+		switch strings.ToLower(strings.TrimSpace(mt)) {
+		case "application/x-ndjson", "application/jsonl":
+			f = formatNDJSON
+		case "text/csv":
+			f = formatCSV
+		case "application/json":
+			f = formatJSON
+		default:
+			continue
+		}
+		if q := qValue(params); q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best, true
+}
+
+func acceptsGzip(r *http.Request) bool {
+	if v := r.URL.Query().Get("gzip"); v != "" {
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	// явный gzip (x-gzip) важнее *; q=0 — кодировка запрещена
+	gzipQ, anyQ := -1.0, -1.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		switch strings.ToLower(strings.TrimSpace(enc)) {
+		case "gzip", "x-gzip":
+			gzipQ = max(gzipQ, qValue(params))
+		case "*":
+			anyQ = max(anyQ, qValue(params))
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+// qValue — вес q из параметров элемента Accept-*; без q — 1, кривой q — 0
+func qValue(params string) float64 {
+	for _, p := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// export стримит записи в NDJSON или CSV. Без сортировки и пагинации записи
+// читаются из store пачками (Store.Scan), иначе — страницей из Store.Query.
+func (s *Server) export(w http.ResponseWriter, r *http.Request, q cache.Query, format string, fields []string) {
+	each := func(fn func(model.RTTRecord) error) error {
+		return s.Store.Scan(q, fn)
+	}
+	if q.Sort != "" || q.Limit > 0 || q.Cursor != "" {
+		recs, next, err := s.Store.Query(q)
+		if err != nil {
+			writeParamError(w, err)
+			return
+		}
+		if next != "" {
+			w.Header().Set("X-Next-Cursor", next)
+		}
+		each = func(fn func(model.RTTRecord) error) error {
+			for _, rec := range recs {
+				if err := fn(rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	switch format {
+	case formatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="rtt.csv"`)
+	}
+
+	var out io.Writer = w
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if gz, ok := out.(*gzip.Writer); ok {
+			_ = gz.Flush()
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	n := 0
+	var err error
+	switch format {
+	case formatNDJSON:
+		enc := json.NewEncoder(out)
+		err = each(func(rec model.RTTRecord) error {
+			var v any = rec
+			if len(fields) > 0 {
+				p, err := project([]model.RTTRecord{rec}, fields)
+				if err != nil {
+					return err
+				}
+				v = p[0]
+			}
+			if n++; n%scanFlushEvery == 0 {
+				flush()
+			}
+			return enc.Encode(v)
+		})
+	case formatCSV:
+		cols := csvColumns
+		if len(fields) > 0 {
+			cols = selectColumns(fields)
+		}
+		cw := csv.NewWriter(out)
+		header := make([]string, len(cols))
+		for i, c := range cols {
+			header[i] = c.name
+		}
+		_ = cw.Write(header)
+		row := make([]string, len(cols))
+		err = each(func(rec model.RTTRecord) error {
+			for i, c := range cols {
+				row[i] = c.value(rec)
+			}
+			if n++; n%scanFlushEvery == 0 {
+				cw.Flush()
+				flush()
+			}
+			return cw.Write(row)
+		})
+		cw.Flush()
+	}
+	if err != nil {
+		log.Printf("export %s: %v", format, err)
+	}
+}
+
+const scanFlushEvery = 512
+
+type csvColumn struct {
+	name  string
+	value func(model.RTTRecord) string
+}
+
+func ftoa(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+func geoCol(f func(*model.Geo) string) func(model.RTTRecord) string {
+	return func(r model.RTTRecord) string {
+		if r.Geo == nil {
+			return ""
+		}
+		return f(r.Geo)
+	}
+}
+
+var csvColumns = []csvColumn{
+	{"ip", func(r model.RTTRecord) string { return r.IP }},
+	{"listener_port", func(r model.RTTRecord) string { return strconv.Itoa(r.ListenerPort) }},
+	{"tcpi_rtt_us", func(r model.RTTRecord) string { return strconv.FormatUint(uint64(r.TCPI_RTT_us), 10) }},
+	{"tcpi_rtt_ms", func(r model.RTTRecord) string { return ftoa(r.RTT_ms) }},
+	{"tcpi_rttvar_us", func(r model.RTTRecord) string { return strconv.FormatUint(uint64(r.TCPI_VAR_us), 10) }},
+	{"tcpi_rttvar_ms", func(r model.RTTRecord) string { return ftoa(r.RTTVar_ms) }},
+	{"country", geoCol(func(g *model.Geo) string { return g.Country })},
+	{"region", geoCol(func(g *model.Geo) string { return g.Region })},
+	{"city", geoCol(func(g *model.Geo) string { return g.City })},
+	{"latitude", geoCol(func(g *model.Geo) string { return ftoa(g.Latitude) })},
+	{"longitude", geoCol(func(g *model.Geo) string { return ftoa(g.Longitude) })},
+	{"distance_to_server_km", func(r model.RTTRecord) string {
+		if r.DistanceToServer == nil {
+			return ""
+		}
+		return ftoa(*r.DistanceToServer)
+	}},
+	{"asn", func(r model.RTTRecord) string {
+		if r.ASN == 0 {
+			return ""
+		}
+		return strconv.Itoa(r.ASN)
+	}},
+	{"as_org", func(r model.RTTRecord) string { return r.ASOrg }},
+	{"network_type", func(r model.RTTRecord) string { return r.NetworkType }},
+	{"id_probe_globalping", func(r model.RTTRecord) string { return r.IDProbeGlabal }},
+	{"globalping_rtt_ms", func(r model.RTTRecord) string {
+		if r.GlobalpingRTT == 0 {
+			return ""
+		}
+		return ftoa(r.GlobalpingRTT)
+	}},
+	{"globalping_confidence", func(r model.RTTRecord) string {
+		if r.GlobalpingConfidence == 0 {
+			return ""
+		}
+		return ftoa(r.GlobalpingConfidence)
+	}},
+	{"ptr", func(r model.RTTRecord) string { return r.PTR }},
+	{"reputation", func(r model.RTTRecord) string { return strings.Join(r.Reputation, ";") }},
+	{"vpn_score", func(r model.RTTRecord) string {
+		if r.VPN == nil {
+			return ""
+		}
+		return ftoa(r.VPN.Score)
+	}},
+	{"vpn_verdict", func(r model.RTTRecord) string {
+		if r.VPN == nil {
+			return ""
+		}
+		return r.VPN.Verdict
+	}},
+	{"feasible", func(r model.RTTRecord) string {
+		if r.Feasibility == nil {
+			return ""
+		}
+		return strconv.FormatBool(r.Feasibility.Feasible)
+	}},
+	{"max_distance_km", func(r model.RTTRecord) string { return ftoa(r.MaxDistanceKm) }},
+	{"updated_at", func(r model.RTTRecord) string { return r.UpdatedAt.UTC().Format(time.RFC3339Nano) }},
+}
+
+// selectColumns — колонки в порядке fields; неизвестные имена пропускаются
+func selectColumns(fields []string) []csvColumn {
+	out := make([]csvColumn, 0, len(fields))
+	for _, f := range fields {
+		for _, c := range csvColumns {
+			if c.name == f {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	layerClients = "clients"
+	layerProbes  = "probes"
+)
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string         `json:"type"`
+	Geometry   point          `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // [lon, lat]
+}
+
+func newFeature(lat, lon float64, props map[string]any) feature {
+	return feature{Type: "Feature", Geometry: point{Type: "Point", Coordinates: [2]float64{lon, lat}}, Properties: props}
+}
+
+// probeAcc собирает одну и ту же пробу Globalping из измерений разных клиентов
+type probeAcc struct {
+	lat     float64
+	lon     float64
+	props   map[string]any
+	rtts    []float64
+	clients map[string]struct{}
+}
+
+// getGeoJSON: фильтры те же, что у /rtt/all, без сортировки и пагинации;
+// layer=clients|probes, по умолчанию оба слоя, слой записан в properties.layer
+func (s *Server) getGeoJSON(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	q, err := parseFilters(vals)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	layers := splitList(vals.Get("layer"))
+	if len(layers) == 0 {
+		layers = []string{layerClients, layerProbes}
+	}
+	for _, l := range layers {
+		if l != layerClients && l != layerProbes {
+			writeParamError(w, &paramError{"layer", vals.Get("layer"), "expected clients, probes or both"})
+			return
+		}
+	}
+	withClients := containsFold(layers, layerClients)
+	withProbes := containsFold(layers, layerProbes)
+
+	fc := featureCollection{Type: "FeatureCollection", Features: []feature{}}
+	probes := make(map[string]*probeAcc)
+	err = s.Store.Scan(q, func(rec model.RTTRecord) error {
+		if withClients && rec.Geo != nil && (rec.Geo.Latitude != 0 || rec.Geo.Longitude != 0) {
+			fc.Features = append(fc.Features, newFeature(rec.Geo.Latitude, rec.Geo.Longitude, clientProps(rec)))
+		}
+		if !withProbes {
+			return nil
+		}
+		for _, p := range rec.InfoProbes {
+			if p.Latitude == 0 && p.Longitude == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%.4f,%.4f,%d", p.Latitude, p.Longitude, p.ASN)
+			if p.IP != nil {
+				key = *p.IP
+			}
+			acc := probes[key]
+			if acc == nil {
+				acc = &probeAcc{lat: p.Latitude, lon: p.Longitude, clients: make(map[string]struct{}),
+					props: map[string]any{
+						"layer": layerProbes, "asn": p.ASN, "network": p.Network,
+						"country": p.Country, "city": p.City, "distance_km": p.Distance,
+					}}
+				probes[key] = acc
+			}
+			if p.RTTms > 0 {
+				acc.rtts = append(acc.rtts, p.RTTms)
+			}
+			acc.clients[rec.IP] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+
+	keys := make([]string, 0, len(probes))
+	for k := range probes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		acc := probes[k]
+		acc.props["clients"] = len(acc.clients)
+		if len(acc.rtts) > 0 {
+			acc.props["rtt_median_ms"] = utils.Median(acc.rtts)
+		}
+		fc.Features = append(fc.Features, newFeature(acc.lat, acc.lon, acc.props))
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	_ = json.NewEncoder(w).Encode(fc)
+}
+
+func clientProps(rec model.RTTRecord) map[string]any {
+	p := map[string]any{
+		"layer":      layerClients,
+		"ip":         rec.IP,
+		"rtt_ms":     rec.RTT_ms,
+		"rttvar_ms":  rec.RTTVar_ms,
+		"country":    rec.Geo.Country,
+		"region":     rec.Geo.Region,
+		"city":       rec.Geo.City,
+		"updated_at": rec.UpdatedAt,
+	}
+	if rec.ASN != 0 {
+		p["asn"] = rec.ASN
+		p["as_org"] = rec.ASOrg
+	}
+	if rec.NetworkType != "" {
+		p["network_type"] = rec.NetworkType
+	}
+	if rec.DistanceToServer != nil {
+		p["distance_km"] = *rec.DistanceToServer
+	}
+	if rec.GlobalpingRTT > 0 {
+		p["globalping_rtt_ms"] = rec.GlobalpingRTT
+	}
+	if rec.VPN != nil {
+		p["vpn_verdict"] = rec.VPN.Verdict
+	}
+	return p
+}
+
+type heatCell struct {
+	Lat         float64    `json:"lat"`
+	Lon         float64    `json:"lon"`
+	BBox        [4]float64 `json:"bbox"` // [west, south, east, north]
+	Count       int        `json:"count"`
+	RTTMinMS    float64    `json:"rtt_min_ms"`
+	RTTMedianMS float64    `json:"rtt_median_ms"`
+	RTTP95MS    float64    `json:"rtt_p95_ms"`
+}
+
+type heatmap struct {
+	CellDeg float64    `json:"cell_deg"`
+	Cells   []heatCell `json:"cells"`
+}
+
+const (
+	defaultCellDeg = 1.0
+	minCellDeg     = 0.1
+	maxCellDeg     = 30.0
+)
+
+// getHeatmap раскладывает клиентов с координатами по ячейкам cell×cell градусов
+func (s *Server) getHeatmap(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	q, err := parseFilters(vals)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	cell := defaultCellDeg
+	if v := strings.TrimSpace(vals.Get("cell")); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < minCellDeg || f > maxCellDeg {
+			writeParamError(w, &paramError{"cell", v, fmt.Sprintf("expected cell size in degrees, %g..%g", minCellDeg, maxCellDeg)})
+			return
+		}
+		cell = f
+	}
+
+	type cellKey struct{ y, x int }
+	rtts := make(map[cellKey][]float64)
+	err = s.Store.Scan(q, func(rec model.RTTRecord) error {
+		if rec.Geo == nil || (rec.Geo.Latitude == 0 && rec.Geo.Longitude == 0) {
+			return nil
+		}
+		k := cellKey{int(math.Floor((rec.Geo.Latitude + 90) / cell)), int(math.Floor((rec.Geo.Longitude + 180) / cell))}
+		rtts[k] = append(rtts[k], rec.RTT_ms)
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+		return
+	}
+
+	out := heatmap{CellDeg: cell, Cells: make([]heatCell, 0, len(rtts))}
+	for k, xs := range rtts {
+		south, west := float64(k.y)*cell-90, float64(k.x)*cell-180
+		north, east := math.Min(south+cell, 90), math.Min(west+cell, 180)
+		out.Cells = append(out.Cells, heatCell{
+			Lat:         (south + north) / 2,
+			Lon:         (west + east) / 2,
+			BBox:        [4]float64{west, south, east, north},
+			Count:       len(xs),
+			RTTMinMS:    slices.Min(xs),
+			RTTMedianMS: utils.Median(xs),
+			RTTP95MS:    utils.Quantile(xs, 0.95),
+		})
+	}
+	sort.Slice(out.Cells, func(i, j int) bool {
+		if out.Cells[i].Lat != out.Cells[j].Lat {
+			return out.Cells[i].Lat > out.Cells[j].Lat
+		}
+		return out.Cells[i].Lon < out.Cells[j].Lon
+	})
+	writeJSON(w, out)
+}
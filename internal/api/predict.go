@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+func (s *Server) getPredict(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.URL.Query().Get("ip"))
+	if ip == "" {
+		writeError(w, http.StatusBadRequest, "missing_parameter", "use /v1/rtt/predict?ip=1.2.3.4",
+			map[string]any{"parameter": "ip"})
+		return
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		writeParamError(w, &paramError{"ip", ip, "ip is not a valid address"})
+		return
+	}
+	// частные и служебные адреса не прогнозируются и не уходят в геолокацию
+	if addr = addr.Unmap(); !isPublic(addr) {
+		writeParamError(w, &paramError{"ip", ip, "ip is not a globally routable address"})
+		return
+	}
+	if s.Predictor == nil {
+		writeError(w, http.StatusServiceUnavailable, "unavailable", "predictor is not enabled", nil)
+		return
+	}
+	writeJSON(w, s.Predictor.Predict(r.Context(), addr))
+}
+
+func (s *Server) getPredictModel(w http.ResponseWriter, r *http.Request) {
+	if s.Predictor == nil {
+		writeError(w, http.StatusServiceUnavailable, "unavailable", "predictor is not enabled", nil)
+		return
+	}
+	writeJSON(w, s.Predictor.Fit())
+}
+
+// зарезервированные диапазоны, которые netip не выделяет отдельно
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+func isPublic(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxLimit = 10000
+
+type paramError struct {
+	param, value, msg string
+}
+
+func (e *paramError) Error() string { return e.param + ": " + e.msg }
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func parseInts(param, v string) ([]int, error) {
+	var out []int
+	for _, s := range splitList(v) {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(s), "AS"))
+		if err != nil {
+			return nil, &paramError{param, v, "expected comma-separated integers"}
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func parseFloat(vals url.Values, param string) (*float64, error) {
+	v := strings.TrimSpace(vals.Get(param))
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, &paramError{param, v, "expected a number"}
+	}
+	return &f, nil
+}
+
+// parseSince принимает RFC3339 или длительность назад от текущего момента ("15m")
+func parseSince(param, v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, &paramError{param, v, "expected RFC3339 time or duration like 15m"}
+}
+
+// parseQuery разбирает параметры /rtt/all в cache.Query
+func parseQuery(vals url.Values) (cache.Query, error) {
+	var q cache.Query
+	var err error
+
+	q.Countries = splitList(vals.Get("country"))
+	q.Reputation = splitList(vals.Get("reputation"))
+	q.NetworkTypes = splitList(vals.Get("network_type"))
+	if q.ASNs, err = parseInts("asn", vals.Get("asn")); err != nil {
+		return q, err
+	}
+	if q.Ports, err = parseInts("port", vals.Get("port")); err != nil {
+		return q, err
+	}
+	if q.MinRTTms, err = parseFloat(vals, "min_rtt_ms"); err != nil {
+		return q, err
+	}
+	if q.MaxRTTms, err = parseFloat(vals, "max_rtt_ms"); err != nil {
+		return q, err
+	}
+	if q.MinDistanceKm, err = parseFloat(vals, "min_distance_km"); err != nil {
+		return q, err
+	}
+	if q.MaxDistanceKm, err = parseFloat(vals, "max_distance_km"); err != nil {
+		return q, err
+	}
+	if q.UpdatedSince, err = parseSince("updated_since", strings.TrimSpace(vals.Get("updated_since"))); err != nil {
+		return q, err
+	}
+
+	if s := strings.TrimSpace(vals.Get("sort")); s != "" {
+		q.Desc = strings.HasPrefix(s, "-")
+		q.Sort = strings.TrimPrefix(s, "-")
+		if !isSortField(q.Sort) {
+			return q, &paramError{"sort", s, "expected one of " + strings.Join(cache.SortFields(), ", ") + ", optionally prefixed with -"}
+		}
+	}
+	if v := strings.TrimSpace(vals.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return q, &paramError{"limit", v, fmt.Sprintf("expected integer in 1..%d", maxLimit)}
+		}
+		q.Limit = n
+	}
+	q.Cursor = strings.TrimSpace(vals.Get("cursor"))
+	return q, nil
+}
+
+// parseFilters — только фильтры /rtt/all для эндпоинтов, которые обходят
+// все подходящие записи: сортировка и пагинация там не применяются
+func parseFilters(vals url.Values) (cache.Query, error) {
+	for _, p := range []string{"sort", "limit", "cursor"} {
+		if v := vals.Get(p); v != "" {
+			return cache.Query{}, &paramError{p, v, "not supported by this endpoint"}
+		}
+	}
+	return parseQuery(vals)
+}
+
+func isSortField(f string) bool {
+	for _, s := range cache.SortFields() {
+		if s == f {
+			return true
+		}
+	}
+	return false
+}
+
+// project оставляет в каждой записи только перечисленные json-поля
+func project(recs []model.RTTRecord, fields []string) ([]map[string]json.RawMessage, error) {
+	out := make([]map[string]json.RawMessage, 0, len(recs))
+	for _, rec := range recs {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+		m := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				m[f] = v
+			}
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
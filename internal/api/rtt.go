@@ -0,0 +1,216 @@
+package api
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"RTTServer/internal/upstream"
+	"RTTServer/internal/utils"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (s *Server) getRTT(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.URL.Query().Get("ip"))
+	if ip == "" {
+		writeError(w, http.StatusBadRequest, "missing_parameter", "use /v1/rtt?ip=1.2.3.4 or /v1/rtt/all",
+			map[string]any{"parameter": "ip"})
+		return
+	}
+	if _, err := netip.ParseAddr(ip); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "ip is not a valid address",
+			map[string]any{"parameter": "ip", "value": ip})
+		return
+	}
+	rec, ok := s.Store.Get(ip)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "not found or expired", map[string]any{"ip": ip})
+		return
+	}
+	writeJSON(w, rec)
+}
+
+// getHistory отдаёт замеры ip за cache.HistoryTTL, даже если сама запись уже протухла
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.URL.Query().Get("ip"))
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		writeParamError(w, &paramError{"ip", ip, "ip is not a valid address"})
+		return
+	}
+	ip = addr.Unmap().String()
+	samples := s.Store.History(ip)
+	if len(samples) == 0 {
+		writeError(w, http.StatusNotFound, "not_found", "no measurements for this ip", map[string]any{"ip": ip})
+		return
+	}
+	writeJSON(w, map[string]any{"ip": ip, "samples": samples})
+}
+
+// getAll: фильтры и сортировка — см. parseQuery; курсор следующей страницы
+// отдаётся в X-Next-Cursor и Link, тело остаётся массивом записей
+func (s *Server) getAll(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	q, err := parseQuery(vals)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "format must be json, ndjson or csv",
+			map[string]any{"parameter": "format", "value": vals.Get("format")})
+		return
+	}
+	// ответ зависит от Accept и Accept-Encoding в любом формате
+	w.Header().Add("Vary", "Accept, Accept-Encoding")
+	if format != formatJSON {
+		s.export(w, r, q, format, splitList(vals.Get("fields")))
+		return
+	}
+	recs, next, err := s.Store.Query(q)
+	if errors.Is(err, cache.ErrBadCursor) {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "cursor is malformed",
+			map[string]any{"parameter": "cursor"})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", err.Error(), nil)
+		return
+	}
+	if next != "" {
+		vals.Set("cursor", next)
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, vals.Encode()))
+	}
+
+	if fields := splitList(vals.Get("fields")); len(fields) > 0 {
+		out, err := project(recs, fields)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal", err.Error(), nil)
+			return
+		}
+		writeJSON(w, out)
+		return
+	}
+	writeJSON(w, recs)
+}
+
+func writeParamError(w http.ResponseWriter, err error) {
+	var pe *paramError
+	if errors.As(err, &pe) {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", pe.msg,
+			map[string]any{"parameter": pe.param, "value": pe.value})
+		return
+	}
+	writeError(w, http.StatusBadRequest, "invalid_parameter", err.Error(), nil)
+}
+
+func (s *Server) getGroup(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	key, ok := groupKeys[by]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "by must be one of network_type, country, asn",
+			map[string]any{"parameter": "by", "value": by})
+		return
+	}
+	writeJSON(w, groupRecords(s.Store.AllFresh(), key))
+}
+
+// getWhoami — адрес клиента, каким его видит сервер; rttctl по нему
+// находит свой замер
+func (s *Server) getWhoami(w http.ResponseWriter, r *http.Request) {
+	ip := peerIP(r.RemoteAddr)
+	writeJSON(w, map[string]string{"ip": ip})
+}
+
+func peerIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
+
+func (s *Server) getHealth(w http.ResponseWriter, r *http.Request) {
+	ups := upstream.HealthAll()
+	status := "ok"
+	for _, u := range ups {
+		if !u.Optional && u.Breaker.State != upstream.StateClosed {
+			status = "degraded"
+		}
+	}
+	writeJSON(w, map[string]any{
+		"status":    status,
+		"upstreams": ups,
+		"enrichers": s.Enrichers,
+	})
+}
+
+func (s *Server) getRetries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Retries.Pending())
+}
+
+func (s *Server) getRaw(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	body, ok := s.Raws.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "not found or expired", map[string]any{"id": id})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+var groupKeys = map[string]func(model.RTTRecord) string{
+	"network_type": func(r model.RTTRecord) string { return r.NetworkType },
+	"country": func(r model.RTTRecord) string {
+		if r.Geo == nil {
+			return ""
+		}
+		return r.Geo.Country
+	},
+	"asn": func(r model.RTTRecord) string {
+		if r.ASN == 0 {
+			return ""
+		}
+		return strconv.Itoa(r.ASN)
+	},
+}
+
+type group struct {
+	Key         string  `json:"key"`
+	Count       int     `json:"count"`
+	RTTMedianMS float64 `json:"rtt_median_ms"`
+	RTTP95MS    float64 `json:"rtt_p95_ms"`
+}
+
+func groupRecords(recs []model.RTTRecord, key func(model.RTTRecord) string) []group {
+	rtts := make(map[string][]float64)
+	for _, rec := range recs {
+		k := key(rec)
+		if k == "" {
+			k = "unknown"
+		}
+		rtts[k] = append(rtts[k], rec.RTT_ms)
+	}
+	out := make([]group, 0, len(rtts))
+	for k, xs := range rtts {
+		out = append(out, group{
+			Key:         k,
+			Count:       len(xs),
+			RTTMedianMS: utils.Median(xs),
+			RTTP95MS:    utils.Quantile(xs, 0.95),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	return out
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type geoStat struct {
+	Country          string   `json:"country"`
+	Region           string   `json:"region,omitempty"`
+	Clients          int      `json:"clients"`
+	RTTP50MS         float64  `json:"rtt_p50_ms"`
+	RTTP90MS         float64  `json:"rtt_p90_ms"`
+	RTTP95MS         float64  `json:"rtt_p95_ms"`
+	RTTP99MS         float64  `json:"rtt_p99_ms"`
+	DistanceMedianKm *float64 `json:"distance_median_km,omitempty"`
+	BaselineClients  int      `json:"baseline_clients"`
+	BaselineMedianMS *float64 `json:"baseline_median_ms,omitempty"`
+	BaselineRatioP50 *float64 `json:"rtt_baseline_ratio_median,omitempty"`
+}
+
+type statsWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type statsResponse struct {
+	Window statsWindow `json:"window"`
+	Groups []geoStat   `json:"groups"`
+}
+
+func (s *Server) getCountryStats(w http.ResponseWriter, r *http.Request) {
+	s.geoStats(w, r, false)
+}
+
+func (s *Server) getRegionStats(w http.ResponseWriter, r *http.Request) {
+	s.geoStats(w, r, true)
+}
+
+// geoStats: окно задаётся since/until (RFC3339 или длительность назад);
+// хранилище держит только последние замеры за cache.TTL, поэтому окно,
+// уходящее дальше, отклоняется, а не обрезается молча
+func (s *Server) geoStats(w http.ResponseWriter, r *http.Request, byRegion bool) {
+	vals := r.URL.Query()
+	oldest := time.Now().Add(-cache.TTL)
+	from, err := parseSince("since", strings.TrimSpace(vals.Get("since")))
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	to, err := parseSince("until", strings.TrimSpace(vals.Get("until")))
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if to.Before(oldest) {
+		writeParamError(w, &paramError{"until", vals.Get("until"), "until is before record retention of " + cache.TTL.String()})
+		return
+	}
+	if from.IsZero() {
+		from = oldest
+	}
+	if from.After(to) {
+		writeParamError(w, &paramError{"since", vals.Get("since"), "since is after until"})
+		return
+	}
+	if from.Before(oldest) {
+		writeParamError(w, &paramError{"since", vals.Get("since"), "since is before record retention of " + cache.TTL.String()})
+		return
+	}
+	minClients := 1
+	if v := strings.TrimSpace(vals.Get("min_clients")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeParamError(w, &paramError{"min_clients", v, "expected positive integer"})
+			return
+		}
+		minClients = n
+	}
+	countries := splitList(vals.Get("country"))
+
+	buckets := make(map[string]*statBucket)
+	for _, rec := range s.Store.AllFresh() {
+		if rec.UpdatedAt.Before(from) || rec.UpdatedAt.After(to) {
+			continue
+		}
+		country, region := "unknown", ""
+		if rec.Geo != nil && rec.Geo.Country != "" {
+			country = rec.Geo.Country
+			region = rec.Geo.Region
+		}
+		if len(countries) > 0 && !containsFold(countries, country) {
+			continue
+		}
+		key := country
+		if byRegion {
+			if region == "" {
+				region = "unknown"
+			}
+			key += "\x00" + region
+		} else {
+			region = ""
+		}
+		b := buckets[key]
+		if b == nil {
+			b = &statBucket{country: country, region: region}
+			buckets[key] = b
+		}
+		b.add(rec)
+	}
+
+	out := statsResponse{Window: statsWindow{From: from, To: to}, Groups: []geoStat{}}
+	for _, b := range buckets {
+		if len(b.rtts) < minClients {
+			continue
+		}
+		st := geoStat{
+			Country:         b.country,
+			Region:          b.region,
+			Clients:         len(b.rtts),
+			RTTP50MS:        utils.Median(b.rtts),
+			RTTP90MS:        utils.Quantile(b.rtts, 0.90),
+			RTTP95MS:        utils.Quantile(b.rtts, 0.95),
+			RTTP99MS:        utils.Quantile(b.rtts, 0.99),
+			BaselineClients: len(b.bases),
+		}
+		st.DistanceMedianKm = medianPtr(b.dists)
+		st.BaselineMedianMS = medianPtr(b.bases)
+		st.BaselineRatioP50 = medianPtr(b.ratios)
+		out.Groups = append(out.Groups, st)
+	}
+	sort.Slice(out.Groups, func(i, j int) bool {
+		a, b := out.Groups[i], out.Groups[j]
+		if a.Clients != b.Clients {
+			return a.Clients > b.Clients
+		}
+		if a.Country != b.Country {
+			return a.Country < b.Country
+		}
+		return a.Region < b.Region
+	})
+	writeJSON(w, out)
+}
+
+type statBucket struct {
+	country, region            string
+	rtts, dists, bases, ratios []float64
+}
+
+// add: отношение клиентского RTT к baseline Globalping
+// считается только для записей, где baseline есть
+func (b *statBucket) add(rec model.RTTRecord) {
+	b.rtts = append(b.rtts, rec.RTT_ms)
+	if rec.DistanceToServer != nil {
+		b.dists = append(b.dists, *rec.DistanceToServer)
+	}
+	if rec.GlobalpingRTT > 0 {
+		b.bases = append(b.bases, rec.GlobalpingRTT)
+		b.ratios = append(b.ratios, rec.RTT_ms/rec.GlobalpingRTT)
+	}
+}
+
+func medianPtr(xs []float64) *float64 {
+	if len(xs) == 0 {
+		return nil
+	}
+	m := utils.Median(xs)
+	return &m
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
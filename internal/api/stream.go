@@ -0,0 +1,104 @@
+package api
+
+import (
+	"RTTServer/internal/cache"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sseHeartbeat = 15 * time.Second
+
+// getStream — Server-Sent Events с изменениями записей. Фильтры ip, country,
+// asn (через запятую); возобновление по Last-Event-ID или last_event_id.
+func (s *Server) getStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming_unsupported", "response writer can't flush", nil)
+		return
+	}
+	vals := r.URL.Query()
+	ips := splitList(vals.Get("ip"))
+	countries := splitList(vals.Get("country"))
+	asns, err := parseInts("asn", vals.Get("asn"))
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	var after uint64
+	if v := strings.TrimSpace(r.Header.Get("Last-Event-ID")); v != "" {
+		after, _ = strconv.ParseUint(v, 10, 64)
+	} else if v := strings.TrimSpace(vals.Get("last_event_id")); v != "" {
+		if after, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeParamError(w, &paramError{"last_event_id", v, "expected event id"})
+			return
+		}
+	}
+
+	match := func(ev cache.Event) bool {
+		rec := ev.Record
+		if len(ips) > 0 && !slices.Contains(ips, rec.IP) {
+			return false
+		}
+		if len(countries) > 0 && (rec.Geo == nil || !slices.Contains(countries, rec.Geo.Country)) {
+			return false
+		}
+		if len(asns) > 0 && !slices.Contains(asns, rec.ASN) {
+			return false
+		}
+		return true
+	}
+
+	replay, events, complete, cancel := s.Store.Subscribe(after)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		// часть пропущенных событий уже вытеснена из буфера
+		fmt.Fprintf(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, ev := range replay {
+		if match(ev) {
+			writeEvent(w, ev)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case ev, ok := <-events:
+			if !ok {
+				// не успевали читать — клиент переподключится с Last-Event-ID
+				return
+			}
+			if match(ev) {
+				writeEvent(w, ev)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev cache.Event) {
+	b, err := json.Marshal(ev.Record)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, b)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const deliveriesLimit = 100
+
+func (s *Server) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Webhooks.Subscriptions())
+}
+
+// getDeliveries — журнал доставок, новые первыми
+func (s *Server) getDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := deliveriesLimit
+	if v := strings.TrimSpace(r.URL.Query().Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeParamError(w, &paramError{"limit", v, "expected positive integer"})
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, s.Webhooks.Deliveries(strings.TrimSpace(r.URL.Query().Get("subscription")), limit))
+}
@@ -0,0 +1,162 @@
+package asn
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Info struct {
+	ASN     int    `json:"asn"`
+	Country string `json:"country,omitempty"`
+	Org     string `json:"org,omitempty"`
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	info       Info
+}
+
+// DB — офлайн база диапазонов ip -> ASN в формате iptoasn (ip2asn-*.tsv[.gz])
+type DB struct {
+	v4, v6 []ipRange
+}
+
+func Load(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("gzip %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return Parse(r)
+}
+
+func Parse(r io.Reader) (*DB, error) {
+	db := &DB{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		cols := strings.Split(text, "\t")
+		if len(cols) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 columns", line)
+		}
+		start, err := netip.ParseAddr(cols[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := netip.ParseAddr(cols[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		num, err := strconv.Atoi(cols[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad asn %q", line, cols[2])
+		}
+		// 0 в iptoasn — "Not routed"
+		if num == 0 {
+			continue
+		}
+		rg := ipRange{start: start.Unmap(), end: end.Unmap(), info: Info{ASN: num}}
+		if len(cols) > 3 && cols[3] != "None" {
+			rg.info.Country = cols[3]
+		}
+		if len(cols) > 4 {
+			rg.info.Org = cols[4]
+		}
+		if rg.start.Is4() {
+			db.v4 = append(db.v4, rg)
+		} else {
+			db.v6 = append(db.v6, rg)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sortRanges(db.v4)
+	sortRanges(db.v6)
+	return db, nil
+}
+
+func sortRanges(rs []ipRange) {
+	sort.Slice(rs, func(i, j int) bool { return rs[i].start.Less(rs[j].start) })
+}
+
+func (d *DB) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.v4) + len(d.v6)
+}
+
+func (d *DB) Lookup(ip string) (Info, bool) {
+	if d == nil {
+		return Info{}, false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Info{}, false
+	}
+	addr = addr.Unmap()
+	rs := d.v6
+	if addr.Is4() {
+		rs = d.v4
+	}
+	i := sort.Search(len(rs), func(i int) bool { return addr.Less(rs[i].start) })
+	if i == 0 {
+		return Info{}, false
+	}
+	rg := rs[i-1]
+	if addr.Compare(rg.end) > 0 {
+		return Info{}, false
+	}
+	return rg.info, true
+}
+
+// Path схлопывает последовательность ASN хопов в AS-путь: без нулей и повторов подряд
+func Path(asns []int) []int {
+	out := make([]int, 0, len(asns))
+	for _, a := range asns {
+		if a == 0 {
+			continue
+		}
+		if len(out) > 0 && out[len(out)-1] == a {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func EqualPath(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
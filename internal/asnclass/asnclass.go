@@ -0,0 +1,92 @@
+package asnclass
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// типы сетей
+const (
+	Hosting   = "hosting"
+	ISP       = "isp"
+	Mobile    = "mobile"
+	Education = "education"
+)
+
+// Types — допустимые значения network_type
+var Types = []string{Hosting, ISP, Mobile, Education}
+
+const Name = "network_type"
+
+// Classes — классификация ASN из локального файла: на строку "asn<sep>type",
+// разделитель — запятая, таб или пробелы; asn можно писать как AS13335,
+// строки с # и заголовок "asn,type" пропускаются; type — один из Types
+type Classes struct {
+	byASN map[int]string
+}
+
+func Load(path string) (*Classes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+func Parse(r io.Reader) (*Classes, error) {
+	c := &Classes{byASN: make(map[int]string)}
+	sc := bufio.NewScanner(r)
+	line := 0
+	// заголовок — первая строка с данными, перед ним могут быть комментарии
+	first := true
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = strings.TrimSpace(text[:i])
+		}
+		if text == "" {
+			continue
+		}
+		header := first
+		first = false
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == '\t' || r == ' ' || r == ';'
+		})
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: want asn and type", line)
+		}
+		num, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(fields[0]), "AS"))
+		if err != nil {
+			if header {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: bad asn %q", line, fields[0])
+		}
+		t := strings.ToLower(fields[1])
+		if !slices.Contains(Types, t) {
+			return nil, fmt.Errorf("line %d: unknown type %q, want one of %s", line, fields[1], strings.Join(Types, ", "))
+		}
+		c.byASN[num] = t
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Classes) Len() int { return len(c.byASN) }
+
+func (c *Classes) Lookup(asn int) (string, bool) {
+	if c == nil || asn == 0 {
+		return "", false
+	}
+	t, ok := c.byASN[asn]
+	return t, ok
+}
@@ -0,0 +1,23 @@
+package asnclass
+
+import (
+	"RTTServer/internal/enrich"
+	"RTTServer/internal/model"
+	"context"
+)
+
+type Enricher struct {
+	C *Classes
+}
+
+func (e *Enricher) Name() string           { return Name }
+func (e *Enricher) Dependencies() []string { return []string{model.StageASN} }
+
+func (e *Enricher) Enrich(_ context.Context, rec *model.RTTRecord) error {
+	t, ok := e.C.Lookup(rec.ASN)
+	if !ok {
+		return enrich.Skip("asn not classified")
+	}
+	rec.NetworkType = t
+	return nil
+}
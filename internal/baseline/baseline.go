@@ -0,0 +1,147 @@
+package baseline
+
+import (
+	"RTTServer/internal/client"
+	"RTTServer/internal/utils"
+	"math"
+)
+
+const (
+	OutlierNone = "none"
+	OutlierMAD  = "mad"
+	OutlierIQR  = "iqr"
+)
+
+type Config struct {
+	Outlier            string
+	OutlierK           float64
+	Weighted           bool
+	WeightScaleKm      float64
+	DistanceCorrection bool
+	FiberFactor        float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Outlier:            OutlierMAD,
+		OutlierK:           3,
+		Weighted:           true,
+		WeightScaleKm:      100,
+		DistanceCorrection: true,
+		FiberFactor:        0.67,
+	}
+}
+
+type Result struct {
+	RTTms      float64
+	Confidence float64
+	Used       int
+}
+
+// Aggregate считает базовый RTT до сервера по пробам Globalping рядом с клиентом.
+// Пробам выставляются ClientDistance и Outlier. Без координат клиента
+// (clientKnown == false) коррекция и взвешивание по расстоянию не применяются.
+func Aggregate(cfg Config, probes []client.ProbeInfo, clientLat, clientLon float64, clientKnown bool) Result {
+	var clientToServer float64
+	if clientKnown {
+		clientToServer = utils.Haversine(utils.ServerLat, utils.ServerLon, clientLat, clientLon)
+	}
+	kmPerMs := utils.SpeedOfLightKmPerMs * cfg.FiberFactor
+
+	idx := make([]int, 0, len(probes))
+	vals := make([]float64, 0, len(probes))
+	for i := range probes {
+		p := &probes[i]
+		p.Outlier = false
+		if p.RTTms <= 0 {
+			continue
+		}
+		v := p.RTTms
+		if clientKnown {
+			p.ClientDistance = utils.Haversine(p.Latitude, p.Longitude, clientLat, clientLon)
+			if cfg.DistanceCorrection && kmPerMs > 0 {
+				// разница расстояний до сервера не больше расстояния проба-клиент
+				delta := clientToServer - p.Distance
+				delta = math.Max(-p.ClientDistance, math.Min(p.ClientDistance, delta))
+				v = math.Max(0, v+2*delta/kmPerMs)
+			}
+		}
+		idx = append(idx, i)
+		vals = append(vals, v)
+	}
+	if len(vals) == 0 {
+		return Result{}
+	}
+
+	keep := rejectOutliers(cfg, vals)
+	used := make([]float64, 0, len(vals))
+	weights := make([]float64, 0, len(vals))
+	var distSum float64
+	for j, ok := range keep {
+		p := &probes[idx[j]]
+		if !ok {
+			p.Outlier = true
+			continue
+		}
+		w := 1.0
+		if cfg.Weighted && clientKnown && cfg.WeightScaleKm > 0 {
+			w = 1 / (1 + p.ClientDistance/cfg.WeightScaleKm)
+		}
+		used = append(used, vals[j])
+		weights = append(weights, w)
+		distSum += p.ClientDistance
+	}
+
+	est := utils.WeightedMedian(used, weights)
+	return Result{
+		RTTms:      est,
+		Confidence: confidence(used, est, distSum/float64(len(used)), clientKnown),
+		Used:       len(used),
+	}
+}
+
+func rejectOutliers(cfg Config, vals []float64) []bool {
+	keep := make([]bool, len(vals))
+	for i := range keep {
+		keep[i] = true
+	}
+	if len(vals) < 3 || cfg.OutlierK <= 0 {
+		return keep
+	}
+	var lo, hi float64
+	switch cfg.Outlier {
+	case OutlierMAD:
+		med, mad := utils.Median(vals), utils.MAD(vals)
+		if mad == 0 {
+			return keep
+		}
+		lo, hi = med-cfg.OutlierK*1.4826*mad, med+cfg.OutlierK*1.4826*mad
+	case OutlierIQR:
+		q1, q3 := utils.Quantile(vals, 0.25), utils.Quantile(vals, 0.75)
+		lo, hi = q1-cfg.OutlierK*(q3-q1), q3+cfg.OutlierK*(q3-q1)
+	default:
+		return keep
+	}
+	for i, v := range vals {
+		keep[i] = v >= lo && v <= hi
+	}
+	return keep
+}
+
+// confidence в [0,1]: больше проб, меньше разброс и ближе пробы к клиенту — выше
+func confidence(used []float64, est, meanDistKm float64, clientKnown bool) float64 {
+	if len(used) == 0 || est <= 0 {
+		return 0
+	}
+	n := float64(len(used))
+	cN := n / (n + 1)
+	cS := 1.0
+	if len(used) > 1 {
+		cS = math.Max(0, 1-1.4826*utils.MAD(used)/est)
+	}
+	cD := 0.5
+	if clientKnown {
+		cD = 1 / (1 + meanDistKm/500)
+	}
+	return math.Round(cN*cS*cD*1000) / 1000
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"errors"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// размер префикса, по которому агрегируются клиенты
+const (
+	PrefixBits4 = 24
+	PrefixBits6 = 48
+)
+
+// PrefixOf — /24 для IPv4 и /48 для IPv6, в который попадает адрес
+func PrefixOf(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	bits := PrefixBits6
+	if addr.Is4() {
+		bits = PrefixBits4
+	}
+	p, _ := addr.Prefix(bits)
+	return p
+}
+
+// unmapPrefix: ::ffff:a.b.c.d/n -> a.b.c.d/(n-96), маскированная
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	if p.Addr().Is4In6() {
+		p = netip.PrefixFrom(p.Addr().Unmap(), max(p.Bits()-96, 0))
+	}
+	return p.Masked()
+}
+
+// Aggregate — сводка по свежим записям сети
+type Aggregate struct {
+	Prefix           string    `json:"prefix,omitempty"`
+	ASN              int       `json:"asn,omitempty"`
+	ASOrg            string    `json:"as_org,omitempty"`
+	Count            int       `json:"count"`
+	Prefixes         int       `json:"prefixes,omitempty"`
+	ASNs             []int     `json:"asns,omitempty"`
+	RTTP05Ms         float64   `json:"rtt_p05_ms"`
+	RTTMedianMs      float64   `json:"rtt_median_ms"`
+	RTTP95Ms         float64   `json:"rtt_p95_ms"`
+	DistanceMedianKm *float64  `json:"distance_median_km,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// подсеть для PrefixAggregate не шире корзины больше чем на MaxAggregateSpan
+// бит (/16 для IPv4, /40 для IPv6): объединение корзин не кэшируется и
+// считается заново, так его цена ограничена 256 корзинами
+const MaxAggregateSpan = 8
+
+var ErrPrefixTooWide = errors.New("prefix too wide")
+
+// PrefixAggregate отвечает по подсети p. Если p не шире /24 (/48),
+// берётся охватывающий агрегат — так есть ответ и для ещё не виденных ip.
+// Для более широкой подсети объединяются все её /24 (/48).
+func (c *Store) PrefixAggregate(p netip.Prefix) (Aggregate, bool, error) {
+	p = unmapPrefix(p)
+	agg := PrefixOf(p.Addr())
+	span := agg.Bits() - p.Bits()
+	if span > MaxAggregateSpan {
+		return Aggregate{}, false, ErrPrefixTooWide
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if span <= 0 {
+		out, ok := c.cachedAggregate(idxPrefix, agg.String())
+		out.Prefix = agg.String()
+		out.Prefixes = 1
+		return out, ok, nil
+	}
+	ips := make(map[string]struct{})
+	prefixes := 0
+	for i := range 1 << span {
+		set := c.idx[idxPrefix][childPrefix(agg, i).String()]
+		if len(set) == 0 {
+			continue
+		}
+		prefixes++
+		for ip := range set {
+			ips[ip] = struct{}{}
+		}
+	}
+	out, ok, _ := c.aggregate(ips)
+	out.Prefix = p.String()
+	out.Prefixes = prefixes
+	return out, ok, nil
+}
+
+// childPrefix — i-я корзина после first (той же длины)
+func childPrefix(first netip.Prefix, i int) netip.Prefix {
+	b := first.Addr().AsSlice()
+	n := first.Bits() / 8
+	for j := n - 1; j >= 0 && i > 0; j-- {
+		v := int(b[j]) + i
+		b[j], i = byte(v), v>>8
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return netip.PrefixFrom(a, first.Bits())
+}
+
+func (c *Store) ASNAggregate(asn int) (Aggregate, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out, ok := c.cachedAggregate(idxASN, strconv.Itoa(asn))
+	out.ASN = asn
+	out.ASNs = nil
+	return out, ok
+}
+
+// aggCache — сводки по корзинам индексов prefix и asn. Пересчёт — O(k log k)
+// по записям корзины, поэтому результат держится до изменения корзины в
+// put/del или до протухания самой старой записи в нём.
+type aggCache struct {
+	mu sync.Mutex
+	m  map[string]aggEntry
+}
+
+type aggEntry struct {
+	agg     Aggregate
+	ok      bool
+	expires time.Time
+}
+
+func aggKey(field, val string) string { return field + "|" + val }
+
+// cachedAggregate — сводка по корзине field/val; вызывать под c.mu.RLock
+func (c *Store) cachedAggregate(field, val string) (Aggregate, bool) {
+	k := aggKey(field, val)
+	now := time.Now()
+	c.aggs.mu.Lock()
+	e, hit := c.aggs.m[k]
+	c.aggs.mu.Unlock()
+	if hit && now.Before(e.expires) {
+		return e.agg, e.ok
+	}
+	e.agg, e.ok, e.expires = c.aggregate(c.idx[field][val])
+	if !e.ok {
+		// пустые корзины не кэшируются: иначе /predict по случайным ip растил бы кэш
+		return e.agg, false
+	}
+	c.aggs.mu.Lock()
+	c.aggs.m[k] = e
+	c.aggs.mu.Unlock()
+	return e.agg, e.ok
+}
+
+// invalidate сбрасывает сводки корзин записи; вызывать под c.mu.Lock
+func (c *Store) invalidate(rec model.RTTRecord) {
+	keys := indexKeys(rec)
+	c.aggs.mu.Lock()
+	for _, field := range []string{idxPrefix, idxASN} {
+		for _, v := range keys[field] {
+			delete(c.aggs.m, aggKey(field, v))
+		}
+	}
+	c.aggs.mu.Unlock()
+}
+
+// aggregate считает сводку по множеству ip и момент, когда протухнет первая
+// из учтённых записей (zero — записей нет); вызывать под c.mu.RLock
+func (c *Store) aggregate(ips map[string]struct{}) (Aggregate, bool, time.Time) {
+	var out Aggregate
+	var expires time.Time
+	now := time.Now()
+	var rtts, dists []float64
+	asns := make(map[int]struct{})
+	for ip := range ips {
+		rec, ok := c.data[ip]
+		if !ok || now.Sub(rec.UpdatedAt) > TTL {
+			continue
+		}
+		out.Count++
+		if exp := rec.UpdatedAt.Add(TTL); expires.IsZero() || exp.Before(expires) {
+			expires = exp
+		}
+		rtts = append(rtts, rec.RTT_ms)
+		if rec.DistanceToServer != nil {
+			dists = append(dists, *rec.DistanceToServer)
+		}
+		if rec.ASN != 0 {
+			asns[rec.ASN] = struct{}{}
+		}
+		if rec.UpdatedAt.After(out.UpdatedAt) {
+			out.UpdatedAt = rec.UpdatedAt
+			if rec.ASOrg != "" {
+				out.ASOrg = rec.ASOrg
+			}
+		}
+	}
+	if out.Count == 0 {
+		return out, false, expires
+	}
+	out.RTTP05Ms = utils.Quantile(rtts, 0.05)
+	out.RTTMedianMs = utils.Median(rtts)
+	out.RTTP95Ms = utils.Quantile(rtts, 0.95)
+	if len(dists) > 0 {
+		d := utils.Median(dists)
+		out.DistanceMedianKm = &d
+	}
+	for a := range asns {
+		out.ASNs = append(out.ASNs, a)
+	}
+	slices.Sort(out.ASNs)
+	return out, true, expires
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"RTTServer/internal/model"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// BatchResult — ответ Batch; Missing и Expired содержат элементы запроса
+// (ip или CIDR) в каноническом виде, Expired — ещё не вычищенные Janitor'ом
+type BatchResult struct {
+	Found   []model.RTTRecord `json:"found"`
+	Expired []model.RTTRecord `json:"expired"`
+	Missing []string          `json:"missing"`
+}
+
+// Batch ищет сразу много адресов и подсетей за один проход под RLock.
+// Подсети ищутся через индекс prefix (/24, /48): узкие — в своей корзине,
+// широкие — диапазоном по отсортированным ключам индекса.
+// Запись, попавшая под несколько элементов запроса, возвращается один раз.
+func (c *Store) Batch(ips []netip.Addr, prefixes []netip.Prefix) BatchResult {
+	res := BatchResult{Found: []model.RTTRecord{}, Expired: []model.RTTRecord{}, Missing: []string{}}
+	seen := make(map[string]bool)
+	now := time.Now()
+	add := func(rec model.RTTRecord) {
+		if seen[rec.IP] {
+			return
+		}
+		seen[rec.IP] = true
+		rec.Enrichment = cloneStages(rec.Enrichment)
+		rec.Ext = cloneExt(rec.Ext)
+		if now.Sub(rec.UpdatedAt) > TTL {
+			res.Expired = append(res.Expired, rec)
+		} else {
+			res.Found = append(res.Found, rec)
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, ip := range ips {
+		rec, ok := c.data[ip.String()]
+		if !ok {
+			res.Missing = append(res.Missing, ip.String())
+			continue
+		}
+		add(rec)
+	}
+	if len(prefixes) == 0 {
+		return res
+	}
+	var buckets []bucket
+	for _, p := range prefixes {
+		p = unmapPrefix(p)
+		// корзина внутри p — каждый её ip подходит
+		whole := p.Bits() <= PrefixOf(p.Addr()).Bits()
+		hit := false
+		bs := c.prefixBuckets(p, &buckets)
+		for i := range bs {
+			b := &bs[i]
+			if whole && len(b.set) > 0 {
+				hit = true
+			}
+			// широкие подсети часто пересекаются: целиком выданная корзина
+			// второй раз не обходится
+			if b.done {
+				continue
+			}
+			for ip := range b.set {
+				if !whole {
+					addr, err := netip.ParseAddr(ip)
+					if err != nil || !p.Contains(addr.Unmap()) {
+						continue
+					}
+				}
+				hit = true
+				add(c.data[ip])
+			}
+			b.done = whole
+		}
+		if !hit {
+			res.Missing = append(res.Missing, p.String())
+		}
+	}
+	return res
+}
+
+// bucket — корзина индекса prefix; done — уже целиком в ответе
+type bucket struct {
+	prefix netip.Prefix
+	set    map[string]struct{}
+	done   bool
+}
+
+// prefixBuckets — корзины индекса prefix, пересекающиеся с p. sorted —
+// все корзины по возрастанию, строятся при первой подсети шире корзины.
+// Вызывать под c.mu.RLock.
+func (c *Store) prefixBuckets(p netip.Prefix, sorted *[]bucket) []bucket {
+	if agg := PrefixOf(p.Addr()); p.Bits() >= agg.Bits() {
+		return []bucket{{prefix: agg, set: c.idx[idxPrefix][agg.String()]}}
+	}
+	if *sorted == nil {
+		s := make([]bucket, 0, len(c.idx[idxPrefix]))
+		for key, set := range c.idx[idxPrefix] {
+			if kp, err := netip.ParsePrefix(key); err == nil {
+				s = append(s, bucket{prefix: kp, set: set})
+			}
+		}
+		slices.SortFunc(s, func(a, b bucket) int { return a.prefix.Addr().Compare(b.prefix.Addr()) })
+		*sorted = s
+	}
+	s := *sorted
+	i, _ := slices.BinarySearchFunc(s, p.Addr(), func(b bucket, t netip.Addr) int { return b.prefix.Addr().Compare(t) })
+	j := i
+	for j < len(s) && p.Contains(s[j].prefix.Addr()) {
+		j++
+	}
+	return s[i:j]
+}
@@ -11,14 +11,70 @@ const TTL = time.Hour
 type Store struct {
 	mu   sync.RWMutex
 	data map[string]model.RTTRecord
+	idx  index
+
+	history map[string][]Sample
+	events  *hub
+	aggs    aggCache
+}
+
+func New() *Store {
+	return &Store{
+		data:    make(map[string]model.RTTRecord),
+		idx:     newIndex(),
+		history: make(map[string][]Sample),
+		events:  newHub(),
+		aggs:    aggCache{m: make(map[string]aggEntry)},
+	}
+}
+
+// put кладёт запись и обновляет индексы; вызывать под c.mu.Lock
+func (c *Store) put(rec model.RTTRecord) {
+	if old, ok := c.data[rec.IP]; ok {
+		c.idx.remove(old)
+		c.invalidate(old)
+	}
+	c.data[rec.IP] = rec
+	c.idx.add(rec)
+	c.invalidate(rec)
 }
 
-func New() *Store { return &Store{data: make(map[string]model.RTTRecord)} }
+func (c *Store) del(ip string) {
+	if old, ok := c.data[ip]; ok {
+		c.idx.remove(old)
+		c.invalidate(old)
+		delete(c.data, ip)
+	}
+}
 
 func (c *Store) Set(rec model.RTTRecord) {
+	// карта этапов копируется, чтобы вызывающий мог дальше менять свою запись
+	rec.Enrichment = cloneStages(rec.Enrichment)
+	rec.Ext = cloneExt(rec.Ext)
 	c.mu.Lock()
-	c.data[rec.IP] = rec
+	old, ok := c.data[rec.IP]
+	created := !ok || time.Since(old.UpdatedAt) > TTL
+	c.put(rec)
+	c.addSample(rec)
 	c.mu.Unlock()
+	c.events.publish(EventSet, rec, created)
+}
+
+// Restore кладёт запись, только если живой записи для ip нет, — для
+// восстановления из снимка, который мог устареть, пока шло обогащение
+func (c *Store) Restore(rec model.RTTRecord) bool {
+	rec.Enrichment = cloneStages(rec.Enrichment)
+	rec.Ext = cloneExt(rec.Ext)
+	c.mu.Lock()
+	if old, ok := c.data[rec.IP]; ok && time.Since(old.UpdatedAt) <= TTL {
+		c.mu.Unlock()
+		return false
+	}
+	c.put(rec)
+	c.addSample(rec)
+	c.mu.Unlock()
+	c.events.publish(EventSet, rec, true)
+	return true
 }
 
 func (c *Store) Get(ip string) (model.RTTRecord, bool) {
@@ -28,9 +84,43 @@ func (c *Store) Get(ip string) (model.RTTRecord, bool) {
 	if !ok || time.Since(rec.UpdatedAt) > TTL {
 		return model.RTTRecord{}, false
 	}
+	rec.Enrichment = cloneStages(rec.Enrichment)
+	rec.Ext = cloneExt(rec.Ext)
 	return rec, true
 }
 
+// Update меняет сохранённую запись на месте; false, если записи нет, она
+// протухла или fn вернула false — тогда запись не сохраняется и события нет
+func (c *Store) Update(ip string, fn func(*model.RTTRecord) bool) bool {
+	c.mu.Lock()
+	rec, ok := c.data[ip]
+	if !ok || time.Since(rec.UpdatedAt) > TTL {
+		c.mu.Unlock()
+		return false
+	}
+	rec.Enrichment = cloneStages(rec.Enrichment)
+	rec.Ext = cloneExt(rec.Ext)
+	if !fn(&rec) {
+		c.mu.Unlock()
+		return false
+	}
+	c.put(rec)
+	c.mu.Unlock()
+	c.events.publish(EventUpdate, rec, false)
+	return true
+}
+
+func cloneStages(m map[string]model.Stage) map[string]model.Stage {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]model.Stage, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
 func (c *Store) AllFresh() []model.RTTRecord {
 	now := time.Now()
 	c.mu.RLock()
@@ -52,9 +142,21 @@ func (c *Store) Janitor(cleanEvery time.Duration) {
 		c.mu.Lock()
 		for k, v := range c.data {
 			if now.Sub(v.UpdatedAt) > TTL {
-				delete(c.data, k)
+				c.del(k)
 			}
 		}
+		c.pruneHistory(now)
 		c.mu.Unlock()
 	}
 }
+
+func cloneExt(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
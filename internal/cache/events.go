@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"RTTServer/internal/model"
+	"sync"
+	"time"
+)
+
+const (
+	EventSet    = "set"
+	EventUpdate = "update"
+)
+
+type Event struct {
+	ID     uint64          `json:"id"`
+	Type   string          `json:"type"`
+	At     time.Time       `json:"at"`
+	Record model.RTTRecord `json:"record"`
+	// Created — Set для ip, которого в хранилище не было (или запись протухла)
+	Created bool `json:"created,omitempty"`
+}
+
+const (
+	eventBuffer     = 1024 // сколько последних событий хранить для возобновления
+	subscriberQueue = 256
+)
+
+// hub раздаёт события подписчикам. Медленный подписчик, у которого
+// переполнилась очередь, отключается — он может переподключиться с последним ID.
+type hub struct {
+	mu     sync.Mutex
+	nextID uint64
+	ring   []Event
+	subs   map[chan Event]struct{}
+}
+
+// ID начинаются с времени запуска в микросекундах: ID нового процесса
+// больше любого ID прежнего, и Last-Event-ID до рестарта распознаётся как
+// устаревший, а не совпадает со свежими событиями
+func newHub() *hub {
+	return &hub{nextID: uint64(time.Now().UnixMicro()), ring: make([]Event, 0, eventBuffer), subs: make(map[chan Event]struct{})}
+}
+
+func (h *hub) publish(typ string, rec model.RTTRecord, created bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ev := Event{ID: h.nextID, Type: typ, At: time.Now(), Record: rec, Created: created}
+	h.nextID++
+	if len(h.ring) == eventBuffer {
+		copy(h.ring, h.ring[1:])
+		h.ring = h.ring[:eventBuffer-1]
+	}
+	h.ring = append(h.ring, ev)
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe возвращает события после afterID, которые ещё есть в буфере,
+// и канал новых. complete == false — часть событий после afterID уже
+// вытеснена, afterID из прошлого запуска или вообще неизвестен.
+// Канал закрывается при cancel или если подписчик не успевает читать.
+func (c *Store) Subscribe(afterID uint64) (replay []Event, ch <-chan Event, complete bool, cancel func()) {
+	h := c.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	complete = true
+	if afterID > 0 {
+		first := h.nextID
+		if len(h.ring) > 0 {
+			first = h.ring[0].ID
+		}
+		if afterID+1 < first || afterID >= h.nextID {
+			complete = false
+		}
+		for _, ev := range h.ring {
+			if ev.ID > afterID {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	sub := make(chan Event, subscriberQueue)
+	h.subs[sub] = struct{}{}
+	cancel = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[sub]; ok {
+			delete(h.subs, sub)
+			close(sub)
+		}
+	}
+	return replay, sub, complete, cancel
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"RTTServer/internal/model"
+	"time"
+)
+
+// история замеров по ip живёт дольше самих записей
+const (
+	HistoryLen = 100
+	HistoryTTL = 24 * time.Hour
+)
+
+type Sample struct {
+	At            time.Time `json:"at"`
+	RTTms         float64   `json:"rtt_ms"`
+	RTTVarMs      float64   `json:"rttvar_ms"`
+	ListenerPort  int       `json:"listener_port,omitempty"`
+	GlobalpingRTT float64   `json:"globalping_rtt_ms,omitempty"`
+}
+
+// addSample вызывается из Set под c.mu.Lock. HandleConn кладёт один замер
+// дважды (до и после обогащения) с одним UpdatedAt — тогда сэмпл заменяется.
+// Обогащение может закончиться после следующего замера, поэтому ищем не
+// только последний сэмпл.
+func (c *Store) addSample(rec model.RTTRecord) {
+	s := Sample{At: rec.UpdatedAt, RTTms: rec.RTT_ms, RTTVarMs: rec.RTTVar_ms,
+		ListenerPort: rec.ListenerPort, GlobalpingRTT: rec.GlobalpingRTT}
+	h := c.history[rec.IP]
+	for i := len(h) - 1; i >= 0 && !h[i].At.Before(s.At); i-- {
+		if h[i].At.Equal(s.At) {
+			h[i] = s
+			return
+		}
+	}
+	if len(h) == HistoryLen {
+		copy(h, h[1:])
+		h = h[:HistoryLen-1]
+	}
+	c.history[rec.IP] = append(h, s)
+}
+
+// History — замеры ip от старых к новым
+func (c *Store) History(ip string) []Sample {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Sample{}, c.history[ip]...)
+}
+
+// pruneHistory вызывать под c.mu.Lock
+func (c *Store) pruneHistory(now time.Time) {
+	for ip, h := range c.history {
+		if len(h) == 0 || now.Sub(h[len(h)-1].At) > HistoryTTL {
+			delete(c.history, ip)
+		}
+	}
+}
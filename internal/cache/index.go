@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"RTTServer/internal/model"
+	"net/netip"
+	"strconv"
+)
+
+// вторичные индексы: поле -> значение -> множество ip. Меняются только под c.mu.
+const (
+	idxCountry     = "country"
+	idxASN         = "asn"
+	idxPort        = "port"
+	idxReputation  = "reputation"
+	idxNetworkType = "network_type"
+	idxPrefix      = "prefix"
+)
+
+type index map[string]map[string]map[string]struct{}
+
+func newIndex() index {
+	return index{
+		idxCountry:     {},
+		idxASN:         {},
+		idxPort:        {},
+		idxReputation:  {},
+		idxNetworkType: {},
+		idxPrefix:      {},
+	}
+}
+
+func indexKeys(rec model.RTTRecord) map[string][]string {
+	keys := make(map[string][]string, 6)
+	if rec.Geo != nil && rec.Geo.Country != "" {
+		keys[idxCountry] = []string{rec.Geo.Country}
+	}
+	if rec.ASN != 0 {
+		keys[idxASN] = []string{strconv.Itoa(rec.ASN)}
+	}
+	if rec.ListenerPort != 0 {
+		keys[idxPort] = []string{strconv.Itoa(rec.ListenerPort)}
+	}
+	if len(rec.Reputation) > 0 {
+		keys[idxReputation] = rec.Reputation
+	}
+	if rec.NetworkType != "" {
+		keys[idxNetworkType] = []string{rec.NetworkType}
+	}
+	if addr, err := netip.ParseAddr(rec.IP); err == nil {
+		keys[idxPrefix] = []string{PrefixOf(addr).String()}
+	}
+	return keys
+}
+
+func (ix index) add(rec model.RTTRecord) {
+	for field, vals := range indexKeys(rec) {
+		for _, v := range vals {
+			set := ix[field][v]
+			if set == nil {
+				set = make(map[string]struct{})
+				ix[field][v] = set
+			}
+			set[rec.IP] = struct{}{}
+		}
+	}
+}
+
+func (ix index) remove(rec model.RTTRecord) {
+	for field, vals := range indexKeys(rec) {
+		for _, v := range vals {
+			set := ix[field][v]
+			delete(set, rec.IP)
+			if len(set) == 0 {
+				delete(ix[field], v)
+			}
+		}
+	}
+}
+
+// lookup — объединение множеств по значениям одного поля
+func (ix index) lookup(field string, vals []string) map[string]struct{} {
+	if len(vals) == 1 {
+		return ix[field][vals[0]]
+	}
+	out := make(map[string]struct{})
+	for _, v := range vals {
+		for ip := range ix[field][v] {
+			out[ip] = struct{}{}
+		}
+	}
+	return out
+}
@@ -0,0 +1,367 @@
+package cache
+
+import (
+	"RTTServer/internal/model"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query — фильтры, сортировка и пагинация по свежим записям.
+// Пустые поля не фильтруют; несколько значений одного поля — "или".
+type Query struct {
+	Countries    []string
+	ASNs         []int
+	Ports        []int
+	Reputation   []string // имена списков, а также "any" / "none"
+	NetworkTypes []string // "unknown" — без классификации
+
+	MinRTTms, MaxRTTms           *float64
+	MinDistanceKm, MaxDistanceKm *float64
+	UpdatedSince                 time.Time
+
+	Sort   string // поле из SortFields, по умолчанию ip
+	Desc   bool
+	Limit  int // 0 — без ограничения
+	Cursor string
+}
+
+var ErrBadCursor = errors.New("bad cursor")
+
+// keyVal — значение ключа сортировки; ok == false — у записи его нет
+type keyVal struct {
+	Num float64 `json:"n,omitempty"`
+	Str string  `json:"s,omitempty"`
+	OK  bool    `json:"ok,omitempty"`
+}
+
+type sortKey func(model.RTTRecord) keyVal
+
+func num(v float64, ok bool) keyVal { return keyVal{Num: v, OK: ok} }
+func str(s string) keyVal           { return keyVal{Str: s, OK: s != ""} }
+
+var sortKeys = map[string]sortKey{
+	"ip":             func(r model.RTTRecord) keyVal { return str(r.IP) },
+	"rtt":            func(r model.RTTRecord) keyVal { return num(r.RTT_ms, true) },
+	"rttvar":         func(r model.RTTRecord) keyVal { return num(r.RTTVar_ms, true) },
+	"globalping_rtt": func(r model.RTTRecord) keyVal { return num(r.GlobalpingRTT, r.GlobalpingRTT > 0) },
+	"asn":            func(r model.RTTRecord) keyVal { return num(float64(r.ASN), r.ASN != 0) },
+	"updated_at":     func(r model.RTTRecord) keyVal { return num(float64(r.UpdatedAt.UnixMicro()), true) },
+	"distance": func(r model.RTTRecord) keyVal {
+		if r.DistanceToServer == nil {
+			return keyVal{}
+		}
+		return num(*r.DistanceToServer, true)
+	},
+	"country": func(r model.RTTRecord) keyVal {
+		if r.Geo == nil {
+			return keyVal{}
+		}
+		return str(r.Geo.Country)
+	},
+}
+
+func SortFields() []string {
+	out := make([]string, 0, len(sortKeys))
+	for k := range sortKeys {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// compareKeys: записи без значения ключа всегда в конце, при равенстве — по ip
+func compareKeys(a keyVal, aIP string, b keyVal, bIP string, desc bool) int {
+	if a.OK != b.OK {
+		if a.OK {
+			return -1
+		}
+		return 1
+	}
+	var c int
+	if a.Num != b.Num {
+		c = cmp.Compare(a.Num, b.Num)
+	} else {
+		c = strings.Compare(a.Str, b.Str)
+	}
+	if desc {
+		c = -c
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(aIP, bIP)
+}
+
+// cursor — позиция последней отданной записи
+type cursor struct {
+	Key keyVal `json:"k"`
+	IP  string `json:"ip"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrBadCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.IP == "" {
+		return c, ErrBadCursor
+	}
+	return c, nil
+}
+
+// Query возвращает страницу записей и курсор следующей страницы ("" — последняя)
+func (c *Store) Query(q Query) ([]model.RTTRecord, string, error) {
+	if q.Sort == "" {
+		q.Sort = "ip"
+	}
+	key, ok := sortKeys[q.Sort]
+	if !ok {
+		return nil, "", errors.New("unknown sort field " + q.Sort)
+	}
+	var after *cursor
+	if q.Cursor != "" {
+		cur, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &cur
+	}
+
+	out := c.filter(q)
+	if after != nil {
+		out = slices.DeleteFunc(out, func(r model.RTTRecord) bool {
+			return compareKeys(key(r), r.IP, after.Key, after.IP, q.Desc) <= 0
+		})
+	}
+	byKey := func(a, b model.RTTRecord) int {
+		return compareKeys(key(a), a.IP, key(b), b.IP, q.Desc)
+	}
+	// на страницу нужны Limit+1 первых (лишняя — признак следующей страницы):
+	// их отбор O(n log k) вместо сортировки всех совпавших
+	if q.Limit > 0 {
+		out = smallest(out, q.Limit+1, byKey)
+	}
+	slices.SortFunc(out, byKey)
+
+	next := ""
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+		last := out[len(out)-1]
+		next = encodeCursor(cursor{Key: key(last), IP: last.IP})
+	}
+	return out, next, nil
+}
+
+// smallest оставляет k наименьших по cmp записей, порядок не сохраняется.
+// Внутри — max-куча размера k на начале rs.
+func smallest(rs []model.RTTRecord, k int, cmp func(a, b model.RTTRecord) int) []model.RTTRecord {
+	if len(rs) <= k {
+		return rs
+	}
+	h := rs[:k]
+	down := func(i int) {
+		for {
+			m, l, r := i, 2*i+1, 2*i+2
+			if l < k && cmp(h[l], h[m]) > 0 {
+				m = l
+			}
+			if r < k && cmp(h[r], h[m]) > 0 {
+				m = r
+			}
+			if m == i {
+				return
+			}
+			h[i], h[m] = h[m], h[i]
+			i = m
+		}
+	}
+	for i := k/2 - 1; i >= 0; i-- {
+		down(i)
+	}
+	for _, r := range rs[k:] {
+		if cmp(r, h[0]) < 0 {
+			h[0] = r
+			down(0)
+		}
+	}
+	return h
+}
+
+func (c *Store) filter(q Query) []model.RTTRecord {
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cand, useIdx := c.candidates(q)
+	match := func(r model.RTTRecord) bool {
+		return now.Sub(r.UpdatedAt) <= TTL && matches(q, r)
+	}
+	var out []model.RTTRecord
+	if useIdx {
+		out = make([]model.RTTRecord, 0, len(cand))
+		for ip := range cand {
+			if r, ok := c.data[ip]; ok && match(r) {
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+	out = make([]model.RTTRecord, 0, len(c.data))
+	for _, r := range c.data {
+		if match(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// candidates — наименьшее из индексных множеств по фильтрам q; false —
+// индексы не применимы, смотреть все записи. Вызывать под c.mu.
+func (c *Store) candidates(q Query) (map[string]struct{}, bool) {
+	var cand map[string]struct{}
+	useIdx := false
+	narrow := func(field string, vals []string) {
+		if len(vals) == 0 {
+			return
+		}
+		set := c.idx.lookup(field, vals)
+		if !useIdx || len(set) < len(cand) {
+			cand = set
+		}
+		useIdx = true
+	}
+	narrow(idxCountry, q.Countries)
+	narrow(idxASN, itoaAll(q.ASNs))
+	narrow(idxPort, itoaAll(q.Ports))
+	if names := plainNames(q.Reputation); len(names) == len(q.Reputation) {
+		narrow(idxReputation, names)
+	}
+	if !slices.Contains(q.NetworkTypes, "unknown") {
+		narrow(idxNetworkType, q.NetworkTypes)
+	}
+	return cand, useIdx
+}
+
+const scanChunk = 512
+
+// Scan обходит свежие записи под фильтрами q, беря c.mu только на время
+// копирования очередной пачки. Порядок не определён, Sort/Limit/Cursor не
+// учитываются; ошибка fn прерывает обход.
+func (c *Store) Scan(q Query, fn func(model.RTTRecord) error) error {
+	c.mu.RLock()
+	cand, useIdx := c.candidates(q)
+	var ips []string
+	if useIdx {
+		ips = make([]string, 0, len(cand))
+		for ip := range cand {
+			ips = append(ips, ip)
+		}
+	} else {
+		ips = make([]string, 0, len(c.data))
+		for ip := range c.data {
+			ips = append(ips, ip)
+		}
+	}
+	c.mu.RUnlock()
+
+	batch := make([]model.RTTRecord, 0, scanChunk)
+	for len(ips) > 0 {
+		n := min(scanChunk, len(ips))
+		now := time.Now()
+		batch = batch[:0]
+		c.mu.RLock()
+		for _, ip := range ips[:n] {
+			if r, ok := c.data[ip]; ok && now.Sub(r.UpdatedAt) <= TTL && matches(q, r) {
+				batch = append(batch, r)
+			}
+		}
+		c.mu.RUnlock()
+		ips = ips[n:]
+
+		for _, r := range batch {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matches(q Query, r model.RTTRecord) bool {
+	if len(q.Countries) > 0 && (r.Geo == nil || !slices.Contains(q.Countries, r.Geo.Country)) {
+		return false
+	}
+	if len(q.ASNs) > 0 && !slices.Contains(q.ASNs, r.ASN) {
+		return false
+	}
+	if len(q.Ports) > 0 && !slices.Contains(q.Ports, r.ListenerPort) {
+		return false
+	}
+	if len(q.Reputation) > 0 && !matchReputation(q.Reputation, r.Reputation) {
+		return false
+	}
+	if len(q.NetworkTypes) > 0 && !slices.ContainsFunc(q.NetworkTypes, func(t string) bool {
+		return t == r.NetworkType || (t == "unknown" && r.NetworkType == "")
+	}) {
+		return false
+	}
+	if q.MinRTTms != nil && r.RTT_ms < *q.MinRTTms {
+		return false
+	}
+	if q.MaxRTTms != nil && r.RTT_ms > *q.MaxRTTms {
+		return false
+	}
+	if q.MinDistanceKm != nil || q.MaxDistanceKm != nil {
+		if r.DistanceToServer == nil {
+			return false
+		}
+		d := *r.DistanceToServer
+		if (q.MinDistanceKm != nil && d < *q.MinDistanceKm) || (q.MaxDistanceKm != nil && d > *q.MaxDistanceKm) {
+			return false
+		}
+	}
+	if !q.UpdatedSince.IsZero() && r.UpdatedAt.Before(q.UpdatedSince) {
+		return false
+	}
+	return true
+}
+
+func matchReputation(want, got []string) bool {
+	for _, l := range want {
+		if (l == "any" && len(got) > 0) || (l == "none" && len(got) == 0) || slices.Contains(got, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func plainNames(lists []string) []string {
+	out := make([]string, 0, len(lists))
+	for _, l := range lists {
+		if l != "any" && l != "none" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func itoaAll(xs []int) []string {
+	out := make([]string, len(xs))
+	for i, x := range xs {
+		out[i] = strconv.Itoa(x)
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+	"time"
+)
+
+// RawPolicy — сколько и как хранить сырые ответы Globalping
+type RawPolicy struct {
+	MaxAge     time.Duration
+	MaxEntries int
+	Compress   bool
+}
+
+type rawEntry struct {
+	data       []byte
+	compressed bool
+	storedAt   time.Time
+}
+
+// RawStore хранит тело измерения Globalping один раз на measurement id
+type RawStore struct {
+	mu     sync.RWMutex
+	policy RawPolicy
+	data   map[string]rawEntry
+}
+
+func NewRawStore(policy RawPolicy) *RawStore {
+	return &RawStore{policy: policy, data: make(map[string]rawEntry)}
+}
+
+func (s *RawStore) Put(id string, body []byte) {
+	if id == "" || len(body) == 0 {
+		return
+	}
+	e := rawEntry{data: body, storedAt: time.Now()}
+	if s.policy.Compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err == nil && zw.Close() == nil {
+			e.data = buf.Bytes()
+			e.compressed = true
+		}
+	}
+	s.mu.Lock()
+	s.data[id] = e
+	s.mu.Unlock()
+	if s.policy.MaxEntries > 0 {
+		s.Prune()
+	}
+}
+
+func (s *RawStore) Get(id string) ([]byte, bool) {
+	s.mu.RLock()
+	e, ok := s.data[id]
+	s.mu.RUnlock()
+	if !ok || s.expired(e, time.Now()) {
+		return nil, false
+	}
+	if !e.compressed {
+		return e.data, true
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(e.data))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+func (s *RawStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// Prune удаляет просроченные записи и самые старые сверх MaxEntries
+func (s *RawStore) Prune() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, e := range s.data {
+		if s.expired(e, now) {
+			delete(s.data, k)
+		}
+	}
+	for s.policy.MaxEntries > 0 && len(s.data) > s.policy.MaxEntries {
+		var oldestID string
+		var oldest time.Time
+		for k, e := range s.data {
+			if oldestID == "" || e.storedAt.Before(oldest) {
+				oldestID, oldest = k, e.storedAt
+			}
+		}
+		delete(s.data, oldestID)
+	}
+}
+
+func (s *RawStore) Janitor(cleanEvery time.Duration) {
+	t := time.NewTicker(cleanEvery)
+	defer t.Stop()
+	for range t.C {
+		s.Prune()
+	}
+}
+
+func (s *RawStore) expired(e rawEntry, now time.Time) bool {
+	return s.policy.MaxAge > 0 && now.Sub(e.storedAt) > s.policy.MaxAge
+}
@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/http"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -68,25 +67,51 @@ type probeMeta struct {
 	Tags      []string `json:"tags"`
 }
 
+// id — устойчивый ключ пробы между измерениями: своего ID Globalping в
+// результатах не отдаёт, поэтому берутся сеть, город и координаты. ASN в
+// ключ не входит — его смену сравнивают отдельно.
+func (p probeMeta) id() string {
+	return fmt.Sprintf("%s|%s|%s|%.4f,%.4f", p.Country, p.City, p.Network, p.Latitude, p.Longitude)
+}
+
 type ProbeInfo struct {
-	IP         *string  `json:"ip,omitempty"`
-	RTTms      float64  `json:"rtt_ms"`
-	Longitude  float64  `json:"longitude,omitempty"`
-	Latitude   float64  `json:"latitude,omitempty"`
-	ASN        int      `json:"asn,omitempty"`
-	Network    string   `json:"network,omitempty"`
-	Country    string   `json:"country,omitempty"`
-	City       string   `json:"city,omitempty"`
-	Distance   float64  `json:"distance_km,omitempty"`
-	HopCount   int      `json:"hop_count,omitempty"`
-	RawOutputs []string `json:"rawOutputs"`
+	ID        string  `json:"id,omitempty"`
+	IP        *string `json:"ip,omitempty"`
+	RTTms     float64 `json:"rtt_ms"`
+	Longitude float64 `json:"longitude,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	ASN       int     `json:"asn,omitempty"`
+	Network   string  `json:"network,omitempty"`
+	Country   string  `json:"country,omitempty"`
+	City      string  `json:"city,omitempty"`
+	Distance  float64 `json:"distance_km,omitempty"`
+	HopCount  int     `json:"hop_count,omitempty"`
+
+	Hops          []Hop `json:"hops,omitempty"`
+	ASPath        []int `json:"as_path,omitempty"`
+	ASPathChanged bool  `json:"as_path_changed,omitempty"`
+
+	ClientDistance float64 `json:"client_distance_km,omitempty"`
+	Outlier        bool    `json:"outlier,omitempty"`
+}
+
+type Hop struct {
+	N        int     `json:"hop"`
+	Address  string  `json:"address,omitempty"`
+	Hostname string  `json:"hostname,omitempty"`
+	RTTms    float64 `json:"rtt_ms,omitempty"`
+	ASN      int     `json:"asn,omitempty"`
+	ASOrg    string  `json:"as_org,omitempty"`
+
+	PTR          string `json:"ptr,omitempty"`
+	PTRConfirmed bool   `json:"ptr_confirmed,omitempty"`
 }
 
 type GlobalpingAgg struct {
 	MeasurementID string      `json:"id_probe_globalping"`
 	RTTMedianMS   float64     `json:"globalping_rtt_ms"`
 	Probes        []ProbeInfo `json:"info_probes"`
-	RawOutputs    []string    `json:"raw_outputs"`
+	Raw           []byte      `json:"-"`
 }
 type trHop struct {
 	Timings []trTiming `json:"timings"`
@@ -95,8 +120,9 @@ type trTiming struct {
 	RTT float64 `json:"rtt"`
 }
 
-func ClientGlobalping(country, region, city string) (GlobalpingAgg, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// ClientGlobalping: ctx — контекст этапа конвейера, 10s — верхняя граница
+func ClientGlobalping(ctx context.Context, country, region, city string) (GlobalpingAgg, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	return tracerouteTCP(ctx, country, region, city)
 }
@@ -152,7 +178,7 @@ func postOnce(ctx context.Context, loc *location) (string, *errResp, error) {
 	fmt.Println(reqBody)
 	payload, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", nil, fmt.Errorf("marshal: %w")
+		return "", nil, fmt.Errorf("marshal: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.globalping.io/v1/measurements", bytes.NewReader(payload))
@@ -162,7 +188,7 @@ func postOnce(ctx context.Context, loc *location) (string, *errResp, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := globalpingHTTP.Do(req)
 	if err != nil {
 		return "", nil, fmt.Errorf("do request: %w", err)
 	}
@@ -194,7 +220,7 @@ func waitAndExtractAgg(ctx context.Context, id string) (GlobalpingAgg, error) {
 	for {
 		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		req.Header.Set("Accept", "application/json")
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := globalpingHTTP.Do(req)
 		if err != nil {
 			return GlobalpingAgg{}, fmt.Errorf("get measurement: %w", err)
 		}
@@ -214,7 +240,6 @@ func waitAndExtractAgg(ctx context.Context, id string) (GlobalpingAgg, error) {
 		case "finished":
 			rtts := make([]float64, 0, len(m.Results))
 			infos := make([]ProbeInfo, 0, len(m.Results))
-			rawOutputs := make([]string, 0, len(m.Results))
 
 			for _, re := range m.Results {
 				var tr struct {
@@ -233,8 +258,6 @@ func waitAndExtractAgg(ctx context.Context, id string) (GlobalpingAgg, error) {
 					continue
 				}
 
-				rawOutputs = append(rawOutputs, string(body))
-
 				hopCount := 0
 				targetIP := strings.TrimSpace(tr.ResolvedAddress)
 				targetHost := strings.TrimSpace(tr.ResolvedHostname)
@@ -261,6 +284,22 @@ func waitAndExtractAgg(ctx context.Context, id string) (GlobalpingAgg, error) {
 						}
 					}
 				}
+				hops := make([]Hop, 0, len(tr.Hops))
+				for i, h := range tr.Hops {
+					hop := Hop{N: i + 1, Address: h.ResolvedAddress, Hostname: h.ResolvedHostname}
+					var hs float64
+					var hn int
+					for _, t := range h.Timings {
+						if t.RTT > 0 {
+							hs += t.RTT
+							hn++
+						}
+					}
+					if hn > 0 {
+						hop.RTTms = hs / float64(hn)
+					}
+					hops = append(hops, hop)
+				}
 				last := tr.Hops[len(tr.Hops)-1]
 				var sum float64
 				var n int
@@ -275,33 +314,31 @@ func waitAndExtractAgg(ctx context.Context, id string) (GlobalpingAgg, error) {
 				}
 				avg := sum / float64(n)
 				rtts = append(rtts, avg)
-				distance := utils.Haversine(36.102, -115.1447, re.Probe.Latitude, re.Probe.Longitude)
+				distance := utils.Haversine(utils.ServerLat, utils.ServerLon, re.Probe.Latitude, re.Probe.Longitude)
 				infos = append(infos, ProbeInfo{
-					IP:         nil,
-					RTTms:      avg,
-					Longitude:  re.Probe.Longitude,
-					Latitude:   re.Probe.Latitude,
-					ASN:        re.Probe.ASN,
-					Network:    re.Probe.Network,
-					Country:    re.Probe.Country,
-					City:       re.Probe.City,
-					Distance:   distance,
-					HopCount:   hopCount,
-					RawOutputs: rawOutputs,
+					ID:        re.Probe.id(),
+					IP:        nil,
+					RTTms:     avg,
+					Longitude: re.Probe.Longitude,
+					Latitude:  re.Probe.Latitude,
+					ASN:       re.Probe.ASN,
+					Network:   re.Probe.Network,
+					Country:   re.Probe.Country,
+					City:      re.Probe.City,
+					Distance:  distance,
+					HopCount:  hopCount,
+					Hops:      hops,
 				})
 			}
 
 			if len(rtts) == 0 {
 				return GlobalpingAgg{}, fmt.Errorf("finished but no rtt values")
 			}
-			sort.Float64s(rtts)
-			median := rtts[len(rtts)/2]
-
 			return GlobalpingAgg{
 				MeasurementID: id,
-				RTTMedianMS:   median,
+				RTTMedianMS:   utils.Median(rtts),
 				Probes:        infos,
-				RawOutputs:    rawOutputs,
+				Raw:           body,
 			}, nil
 
 		case "error":
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"RTTServer/internal/upstream"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -18,20 +19,28 @@ type ipAPIResp struct {
 	Longitude  float64 `json:"lon"`
 }
 
-func ClientIPAPI(remoteIP string) (string, string, string, float64, float64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+// ClientIPAPI: ctx — контекст этапа конвейера, 2s — верхняя граница
+func ClientIPAPI(ctx context.Context, remoteIP string) (string, string, string, float64, float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
-	return lookupGeo(ctx, remoteIP)
+	return lookupGeo(ctx, ipAPIHTTP, remoteIP)
 }
 
-func lookupGeo(ctx context.Context, ip string) (country, region, city string, Latitude, Longitude float64, err error) {
+// ClientIPAPIAux — геолокация для справочных запросов API через отдельный
+// клиент с лимитом (см. ipAPIAuxHTTP)
+func ClientIPAPIAux(ctx context.Context, remoteIP string) (string, string, string, float64, float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return lookupGeo(ctx, ipAPIAuxHTTP, remoteIP)
+}
+
+func lookupGeo(ctx context.Context, c *upstream.Client, ip string) (country, region, city string, Latitude, Longitude float64, err error) {
 	url := fmt.Sprintf("http://ip-api.com/json/%s?fields=status,country,regionName,city,lat,lon,message", ip)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", "", "", 0, 0, err
 	}
-	var httpClient = &http.Client{Timeout: 3 * time.Second}
-	resp, err := httpClient.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return "", "", "", 0, 0, err
 	}
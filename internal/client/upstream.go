@@ -0,0 +1,46 @@
+package client
+
+import "RTTServer/internal/upstream"
+
+// DefaultAuxRate — лимит справочной геолокации по умолчанию, запросов в секунду
+const DefaultAuxRate = 0.25
+
+var (
+	ipAPIHTTP      = upstream.MustNew("ip-api", upstream.DefaultConfig())
+	globalpingHTTP = upstream.MustNew("globalping", upstream.DefaultConfig())
+	// справочные запросы API (/rtt/predict) идут через свой клиент с лимитом:
+	// анонимные вызовы не расходуют квоту ip-api и не открывают брейкер
+	// обогащения замеров
+	ipAPIAuxHTTP = upstream.MustNewOptional("ip-api:aux", auxConfig(upstream.DefaultConfig(), DefaultAuxRate))
+)
+
+func SetUpstreamConfig(cfg upstream.Config) error {
+	ip, err := upstream.New("ip-api", cfg)
+	if err != nil {
+		return err
+	}
+	gp, err := upstream.New("globalping", cfg)
+	if err != nil {
+		return err
+	}
+	ipAPIHTTP, globalpingHTTP = ip, gp
+	return nil
+}
+
+// SetAuxConfig настраивает клиент справочной геолокации; rate — запросов в секунду
+func SetAuxConfig(cfg upstream.Config, rate float64) error {
+	c, err := upstream.NewOptional("ip-api:aux", auxConfig(cfg, rate))
+	if err != nil {
+		return err
+	}
+	ipAPIAuxHTTP = c
+	return nil
+}
+
+// auxConfig: без повторов — при отказе прогноз обходится без геолокации
+func auxConfig(cfg upstream.Config, rate float64) upstream.Config {
+	cfg.Retries = 0
+	cfg.RateLimit = rate
+	cfg.RateBurst = 5
+	return cfg
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	ASNDBPath      string
+	ASNClassesPath string
+
+	RawMaxAge     time.Duration
+	RawMaxEntries int
+	RawCompress   bool
+
+	BaselineOutlier            string
+	BaselineOutlierK           float64
+	BaselineWeighted           bool
+	BaselineWeightScaleKm      float64
+	BaselineDistanceCorrection bool
+	FiberFactor                float64
+
+	UpstreamTimeout         time.Duration
+	UpstreamRetries         int
+	UpstreamRetryBase       time.Duration
+	UpstreamRetryMax        time.Duration
+	UpstreamBreakerFailures int
+	UpstreamBreakerCooldown time.Duration
+	UpstreamProxy           string
+
+	// DataDir — каталог состояния, переживающего рестарт (очередь повторов)
+	DataDir          string
+	RetryQueuePath   string
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryMaxAttempts int
+	RetryEvery       time.Duration
+
+	Enrichers     []string
+	EnrichTimeout time.Duration
+
+	RDNSEnabled  bool
+	RDNSResolver string
+	RDNSTimeout  time.Duration
+	RDNSTTL      time.Duration
+	RDNSCacheMax int
+
+	ReputationLists       []string
+	ReputationReloadEvery time.Duration
+
+	VPNBaselineRatio    float64
+	VPNBaselineExcessMs float64
+	VPNSuspiciousScore  float64
+	VPNScore            float64
+
+	BatchMax int
+
+	EstimateEvery      time.Duration
+	EstimateRadiusKm   float64
+	EstimatePriorKm    float64
+	EstimateInflation  float64
+	EstimateOverheadMs float64
+	EstimateCellDeg    float64
+
+	PredictEvery       time.Duration
+	PredictPriorASN    float64
+	PredictPriorPrefix float64
+	// геолокация неизвестных ip для /rtt/predict, запросов в минуту; 0 — без неё
+	PredictGeoRate float64
+
+	WebhooksFile    string
+	WebhookTimeout  time.Duration
+	WebhookRetries  int
+	WebhookRetryMax time.Duration
+}
+
+func Load() Config {
+	dataDir := env("RTT_DATA_DIR", defaultDataDir())
+	return Config{
+		ASNDBPath:      env("RTT_ASN_DB", ""),
+		ASNClassesPath: env("RTT_ASN_CLASSES", ""),
+
+		RawMaxAge:     envDuration("RTT_RAW_MAX_AGE", 24*time.Hour),
+		RawMaxEntries: envInt("RTT_RAW_MAX_ENTRIES", 1000),
+		RawCompress:   envBool("RTT_RAW_COMPRESS", true),
+
+		BaselineOutlier:            env("RTT_BASELINE_OUTLIER", "mad"),
+		BaselineOutlierK:           envFloat("RTT_BASELINE_OUTLIER_K", 3),
+		BaselineWeighted:           envBool("RTT_BASELINE_WEIGHTED", true),
+		BaselineWeightScaleKm:      envFloat("RTT_BASELINE_WEIGHT_SCALE_KM", 100),
+		BaselineDistanceCorrection: envBool("RTT_BASELINE_DISTANCE_CORRECTION", true),
+		FiberFactor:                envFloat("RTT_FIBER_FACTOR", 0.67),
+
+		UpstreamTimeout:         envDuration("RTT_UPSTREAM_TIMEOUT", 5*time.Second),
+		UpstreamRetries:         envInt("RTT_UPSTREAM_RETRIES", 2),
+		UpstreamRetryBase:       envDuration("RTT_UPSTREAM_RETRY_BASE", 200*time.Millisecond),
+		UpstreamRetryMax:        envDuration("RTT_UPSTREAM_RETRY_MAX", 2*time.Second),
+		UpstreamBreakerFailures: envInt("RTT_UPSTREAM_BREAKER_FAILURES", 5),
+		UpstreamBreakerCooldown: envDuration("RTT_UPSTREAM_BREAKER_COOLDOWN", 30*time.Second),
+		UpstreamProxy:           env("RTT_UPSTREAM_PROXY", ""),
+
+		DataDir:          dataDir,
+		RetryQueuePath:   dataPath(dataDir, env("RTT_RETRY_QUEUE_PATH", "retry_queue.json")),
+		RetryBaseDelay:   envDuration("RTT_RETRY_BASE_DELAY", time.Minute),
+		RetryMaxDelay:    envDuration("RTT_RETRY_MAX_DELAY", time.Hour),
+		RetryMaxAttempts: envInt("RTT_RETRY_MAX_ATTEMPTS", 6),
+		RetryEvery:       envInterval("RTT_RETRY_EVERY", 15*time.Second),
+
+		Enrichers:     envList("RTT_ENRICHERS"),
+		EnrichTimeout: envDuration("RTT_ENRICH_TIMEOUT", 30*time.Second),
+
+		// PTR по каждому клиенту и хопу — заметная нагрузка на резолвер, включается явно
+		RDNSEnabled:  envBool("RTT_RDNS_ENABLED", false),
+		RDNSResolver: env("RTT_RDNS_RESOLVER", ""),
+		RDNSTimeout:  envDuration("RTT_RDNS_TIMEOUT", 2*time.Second),
+		RDNSTTL:      envDuration("RTT_RDNS_TTL", time.Hour),
+		RDNSCacheMax: envInt("RTT_RDNS_CACHE_MAX", 10000),
+
+		ReputationLists:       envList("RTT_REPUTATION_LISTS"),
+		ReputationReloadEvery: envInterval("RTT_REPUTATION_RELOAD_EVERY", time.Minute),
+
+		VPNBaselineRatio:    envFloat("RTT_VPN_BASELINE_RATIO", 2),
+		VPNBaselineExcessMs: envFloat("RTT_VPN_BASELINE_EXCESS_MS", 30),
+		VPNSuspiciousScore:  envFloat("RTT_VPN_SUSPICIOUS_SCORE", 0.4),
+		VPNScore:            envFloat("RTT_VPN_SCORE", 0.7),
+
+		BatchMax: envInt("RTT_BATCH_MAX", 1000),
+
+		EstimateEvery:      envInterval("RTT_ESTIMATE_EVERY", time.Minute),
+		EstimateRadiusKm:   envFloat("RTT_ESTIMATE_RADIUS_KM", 2000),
+		EstimatePriorKm:    envFloat("RTT_ESTIMATE_PRIOR_KM", 500),
+		EstimateInflation:  envFloat("RTT_ESTIMATE_INFLATION", 1.5),
+		EstimateOverheadMs: envFloat("RTT_ESTIMATE_OVERHEAD_MS", 5),
+		EstimateCellDeg:    envFloat("RTT_ESTIMATE_CELL_DEG", 2),
+
+		PredictEvery:       envInterval("RTT_PREDICT_EVERY", time.Minute),
+		PredictPriorASN:    envFloat("RTT_PREDICT_PRIOR_ASN", 10),
+		PredictPriorPrefix: envFloat("RTT_PREDICT_PRIOR_PREFIX", 3),
+		PredictGeoRate:     envFloat("RTT_PREDICT_GEO_RATE", 15),
+
+		WebhooksFile:    env("RTT_WEBHOOKS_FILE", ""),
+		WebhookTimeout:  envDuration("RTT_WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookRetries:  envInt("RTT_WEBHOOK_RETRIES", 5),
+		WebhookRetryMax: envDuration("RTT_WEBHOOK_RETRY_MAX", 30*time.Second),
+	}
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// defaultDataDir — $XDG_STATE_HOME/rttserver или ~/.local/state/rttserver;
+// без домашнего каталога — /var/lib/rttserver
+func defaultDataDir() string {
+	if d := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(d) {
+		return filepath.Join(d, "rttserver")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "rttserver")
+	}
+	return "/var/lib/rttserver"
+}
+
+// dataPath — относительный путь считается от DataDir, а не от рабочего каталога
+func dataPath(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// envList — список через запятую, пустые элементы отбрасываются
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(env(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func envInt(key string, def int) int {
+	v := env(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("config %s=%q: %v, using %d", key, v, err, def)
+		return def
+	}
+	return n
+}
+
+func envFloat(key string, def float64) float64 {
+	v := env(key, "")
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("config %s=%q: %v, using %g", key, v, err, def)
+		return def
+	}
+	return f
+}
+
+func envBool(key string, def bool) bool {
+	v := env(key, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("config %s=%q: %v, using %t", key, v, err, def)
+		return def
+	}
+	return b
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := env(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("config %s=%q: %v, using %s", key, v, err, def)
+		return def
+	}
+	return d
+}
+
+// envInterval — период тикера: ноль и отрицательные значения уронили бы
+// time.NewTicker, поэтому для них берётся значение по умолчанию
+func envInterval(key string, def time.Duration) time.Duration {
+	d := envDuration(key, def)
+	if d <= 0 {
+		log.Printf("config %s=%s: must be positive, using %s", key, d, def)
+		return def
+	}
+	return d
+}
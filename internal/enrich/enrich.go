@@ -0,0 +1,218 @@
+package enrich
+
+import (
+	"RTTServer/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Enricher дополняет запись данными. Name служит именем этапа в
+// RTTRecord.Enrichment и ключом в RTTRecord.Ext.
+//
+// Куда писать результат: поля, входящие в схему записи (geo, asn, globalping,
+// vpn, feasibility, ptr, reputation, network_type), — типизированные поля
+// верхнего уровня; на них построены индексы хранилища, фильтры и сортировка
+// /rtt/all, агрегаты и фильтры вебхуков. Всё остальное (например внутренний
+// справочник клиентов) — через RTTRecord.SetExt в своё пространство имён.
+type Enricher interface {
+	Name() string
+	Dependencies() []string
+	Enrich(ctx context.Context, rec *model.RTTRecord) error
+}
+
+// Retryable — необязательный интерфейс: неудачи такого enricher'а
+// (обычно внешние API) ставятся в очередь повторов
+type Retryable interface {
+	Retryable() bool
+}
+
+// SoftDependent — необязательный интерфейс: этапы, результат которых
+// используется, если он есть. Статус ok для них не требуется, но RunFrom
+// перезапускает зависящий этап после их повтора.
+type SoftDependent interface {
+	SoftDependencies() []string
+}
+
+func softDependencies(e Enricher) []string {
+	if s, ok := e.(SoftDependent); ok {
+		return s.SoftDependencies()
+	}
+	return nil
+}
+
+var errSkipped = errors.New("skipped")
+
+// Skip — ошибка для этапа, который не выполнялся (нечего обогащать, не настроен и т.п.)
+func Skip(reason string) error { return fmt.Errorf("%w: %s", errSkipped, reason) }
+
+func IsSkip(err error) bool { return errors.Is(err, errSkipped) }
+
+var errDeferred = errors.New("deferred")
+
+// Defer — ошибка для этапа, который доделывается в фоне (см. Background):
+// статус остаётся pending до RunBackground
+func Defer() error { return errDeferred }
+
+// Background — необязательный интерфейс этапа, вернувшего Defer. Медленная
+// часть выполняется после сохранения записи и не задерживает store.Set;
+// возвращённая функция применяет результат к актуальной записи и сама
+// выставляет статус этапа.
+type Background interface {
+	Background(ctx context.Context, rec model.RTTRecord) func(*model.RTTRecord)
+}
+
+type ctxKey int
+
+const (
+	prevKey ctxKey = iota
+	retryKey
+)
+
+// WithPrevious передаёт enricher'ам предыдущую запись того же ip
+func WithPrevious(ctx context.Context, prev model.RTTRecord) context.Context {
+	return context.WithValue(ctx, prevKey, prev)
+}
+
+func Previous(ctx context.Context) (model.RTTRecord, bool) {
+	prev, ok := ctx.Value(prevKey).(model.RTTRecord)
+	return prev, ok
+}
+
+// WithRetry помечает запуск из очереди повторов
+func WithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey, true)
+}
+
+func IsRetry(ctx context.Context) bool {
+	v, _ := ctx.Value(retryKey).(bool)
+	return v
+}
+
+type Pipeline struct {
+	enrichers []Enricher
+	timeout   time.Duration
+}
+
+func (p *Pipeline) Names() []string {
+	out := make([]string, len(p.enrichers))
+	for i, e := range p.enrichers {
+		out[i] = e.Name()
+	}
+	return out
+}
+
+func (p *Pipeline) Retryable(name string) bool {
+	for _, e := range p.enrichers {
+		if e.Name() == name {
+			r, ok := e.(Retryable)
+			return ok && r.Retryable()
+		}
+	}
+	return false
+}
+
+// MarkPending выставляет pending всем этапам конвейера
+func (p *Pipeline) MarkPending(rec *model.RTTRecord) {
+	for _, e := range p.enrichers {
+		rec.SetStage(e.Name(), model.StatusPending, "", nil)
+	}
+}
+
+func (p *Pipeline) Run(ctx context.Context, rec *model.RTTRecord) {
+	for _, e := range p.enrichers {
+		p.runOne(ctx, e, rec)
+	}
+}
+
+// RunFrom запускает name и все этапы, которые от него (транзитивно) зависят.
+// Возвращает имена запущенных этапов.
+func (p *Pipeline) RunFrom(ctx context.Context, rec *model.RTTRecord, name string) []string {
+	affected := map[string]bool{name: true}
+	var ran []string
+	for _, e := range p.enrichers {
+		hit := affected[e.Name()]
+		for _, d := range append(e.Dependencies(), softDependencies(e)...) {
+			if affected[d] {
+				hit = true
+			}
+		}
+		if !hit {
+			continue
+		}
+		affected[e.Name()] = true
+		p.runOne(ctx, e, rec)
+		ran = append(ran, e.Name())
+	}
+	return ran
+}
+
+func (p *Pipeline) runOne(ctx context.Context, e Enricher, rec *model.RTTRecord) {
+	name := e.Name()
+	for _, d := range e.Dependencies() {
+		if st := rec.StageStatus(d); st != model.StatusOK {
+			rec.SetStage(name, model.StatusSkipped, "", fmt.Errorf("dependency %s is %s", d, st))
+			return
+		}
+	}
+	rec.SetStage(name, model.StatusPending, "", nil)
+	// отменённый конвейер не запускает оставшиеся этапы; локальные
+	// enricher'ы (asn, feasibility, vpn, ...) без ввода-вывода проверяют
+	// контекст здесь, внешние запросы получают его сами
+	if err := ctx.Err(); err != nil {
+		rec.SetStage(name, model.StatusFailed, name, err)
+		return
+	}
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	err := e.Enrich(ctx, rec)
+
+	// enricher мог сам выставить статус с источником — его не трогаем
+	if rec.StageStatus(name) != model.StatusPending {
+		return
+	}
+	switch {
+	case errors.Is(err, errDeferred):
+	case err == nil:
+		rec.SetStage(name, model.StatusOK, name, nil)
+	case IsSkip(err):
+		rec.SetStage(name, model.StatusSkipped, name, err)
+	default:
+		log.Printf("enrich %s %s: %v", name, rec.IP, err)
+		rec.SetStage(name, model.StatusFailed, name, err)
+	}
+}
+
+// RunBackground запускает фоновую часть отложенных этапов rec; update —
+// обычно store.Update. Результат не применяется, если запись уже заменил
+// новый замер: у него свой запуск конвейера.
+func (p *Pipeline) RunBackground(ctx context.Context, rec model.RTTRecord, update func(string, func(*model.RTTRecord) bool) bool) {
+	for _, e := range p.enrichers {
+		b, ok := e.(Background)
+		if !ok || rec.StageStatus(e.Name()) != model.StatusPending {
+			continue
+		}
+		go func() {
+			ctx := ctx
+			if p.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, p.timeout)
+				defer cancel()
+			}
+			apply := b.Background(ctx, rec)
+			update(rec.IP, func(cur *model.RTTRecord) bool {
+				if !cur.UpdatedAt.Equal(rec.UpdatedAt) {
+					return false
+				}
+				apply(cur)
+				return true
+			})
+		}()
+	}
+}
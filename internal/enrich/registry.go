@@ -0,0 +1,66 @@
+package enrich
+
+import (
+	"fmt"
+	"time"
+)
+
+type Registry struct {
+	byName map[string]Enricher
+	order  []string
+}
+
+func NewRegistry() *Registry { return &Registry{byName: make(map[string]Enricher)} }
+
+func (r *Registry) Register(e Enricher) error {
+	name := e.Name()
+	if name == "" {
+		return fmt.Errorf("enricher without name")
+	}
+	if _, ok := r.byName[name]; ok {
+		return fmt.Errorf("enricher %q already registered", name)
+	}
+	r.byName[name] = e
+	r.order = append(r.order, name)
+	return nil
+}
+
+func (r *Registry) Names() []string { return append([]string(nil), r.order...) }
+
+// Pipeline собирает конвейер в порядке names; пустой names — все
+// зарегистрированные в порядке регистрации. Зависимость должна стоять раньше
+// зависящего от неё этапа.
+func (r *Registry) Pipeline(names []string, timeout time.Duration) (*Pipeline, error) {
+	if len(names) == 0 {
+		names = r.order
+	}
+	p := &Pipeline{timeout: timeout}
+	seen := make(map[string]bool, len(names))
+	listed := make(map[string]bool, len(names))
+	for _, n := range names {
+		listed[n] = true
+	}
+	for _, n := range names {
+		e, ok := r.byName[n]
+		if !ok {
+			return nil, fmt.Errorf("unknown enricher %q", n)
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("enricher %q listed twice", n)
+		}
+		for _, d := range e.Dependencies() {
+			if !seen[d] {
+				return nil, fmt.Errorf("enricher %q depends on %q, which must come earlier", n, d)
+			}
+		}
+		// мягкая зависимость может отсутствовать, но если есть — раньше
+		for _, d := range softDependencies(e) {
+			if listed[d] && !seen[d] {
+				return nil, fmt.Errorf("enricher %q uses %q, which must come earlier", n, d)
+			}
+		}
+		seen[n] = true
+		p.enrichers = append(p.enrichers, e)
+	}
+	return p, nil
+}
@@ -6,15 +6,97 @@ import (
 )
 
 type RTTRecord struct {
-	IP               string             `json:"ip"`
-	TCPI_RTT_us      uint32             `json:"tcpi_rtt_us"`
-	RTT_ms           float64            `json:"tcpi_rtt_ms"`
-	TCPI_VAR_us      uint32             `json:"tcpi_rttvar_us"`
-	RTTVar_ms        float64            `json:"tcpi_rttvar_ms"`
-	IDProbeGlabal    string             `json:"id_probe_globalping,omitempty"`
-	GlobalpingRTT    float64            `json:"globalping_rtt_ms,omitempty"`
-	InfoProbes       []client.ProbeInfo `json:"info_probes,omitempty"`
-	UpdatedAt        time.Time          `json:"updated_at"`
-	DistanceToServer float64            `json:"distance_to_server_km,omitempty"`
-	Rawdate          []string           `json:"rawdate,omitempty"`
+	IP                   string             `json:"ip"`
+	TCPI_RTT_us          uint32             `json:"tcpi_rtt_us"`
+	RTT_ms               float64            `json:"tcpi_rtt_ms"`
+	TCPI_VAR_us          uint32             `json:"tcpi_rttvar_us"`
+	RTTVar_ms            float64            `json:"tcpi_rttvar_ms"`
+	ListenerPort         int                `json:"listener_port,omitempty"`
+	Geo                  *Geo               `json:"geo,omitempty"`
+	IDProbeGlabal        string             `json:"id_probe_globalping,omitempty"`
+	GlobalpingRTT        float64            `json:"globalping_rtt_ms,omitempty"`
+	GlobalpingConfidence float64            `json:"globalping_confidence,omitempty"`
+	InfoProbes           []client.ProbeInfo `json:"info_probes,omitempty"`
+	UpdatedAt            time.Time          `json:"updated_at"`
+	DistanceToServer     *float64           `json:"distance_to_server_km,omitempty"`
+	ASN                  int                `json:"asn,omitempty"`
+	ASOrg                string             `json:"as_org,omitempty"`
+	NetworkType          string             `json:"network_type,omitempty"`
+	ASPathChanged        bool               `json:"as_path_changed,omitempty"`
+	PTR                  string             `json:"ptr,omitempty"`
+	PTRConfirmed         bool               `json:"ptr_confirmed,omitempty"`
+	Reputation           []string           `json:"reputation,omitempty"`
+	VPN                  *VPNVerdict        `json:"vpn,omitempty"`
+	MaxDistanceKm        float64            `json:"max_distance_km,omitempty"`
+	Feasibility          *Feasibility       `json:"feasibility,omitempty"`
+	Enrichment           map[string]Stage   `json:"enrichment,omitempty"`
+	Ext                  map[string]any     `json:"ext,omitempty"`
+}
+
+type Geo struct {
+	Country   string  `json:"country,omitempty"`
+	Region    string  `json:"region,omitempty"`
+	City      string  `json:"city,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type VPNVerdict struct {
+	Score   float64  `json:"score"`
+	Verdict string   `json:"verdict"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Feasibility — возможна ли геолокация физически при измеренном RTT
+type Feasibility struct {
+	Feasible bool    `json:"feasible"`
+	MinRTTms float64 `json:"min_rtt_ms"`
+}
+
+// этапы обогащения записи
+const (
+	StageGeo         = "geo"
+	StageDistance    = "distance"
+	StageASN         = "asn"
+	StageGlobalping  = "globalping"
+	StageVPN         = "vpn"
+	StageFeasibility = "feasibility"
+)
+
+const (
+	StatusPending = "pending"
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+type Stage struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r *RTTRecord) SetStage(name, status, source string, err error) {
+	if r.Enrichment == nil {
+		r.Enrichment = make(map[string]Stage)
+	}
+	st := Stage{Status: status, Source: source, UpdatedAt: time.Now()}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	r.Enrichment[name] = st
+}
+
+func (r *RTTRecord) StageStatus(name string) string {
+	return r.Enrichment[name].Status
+}
+
+// SetExt кладёт результат enricher'а в его пространство имён. Этапы, чьи
+// поля входят в схему записи, пишут их напрямую (см. enrich.Enricher).
+func (r *RTTRecord) SetExt(name string, v any) {
+	if r.Ext == nil {
+		r.Ext = make(map[string]any)
+	}
+	r.Ext[name] = v
 }
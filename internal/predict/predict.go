@@ -0,0 +1,274 @@
+package predict
+
+import (
+	"RTTServer/internal/asn"
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"context"
+	"math"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// способы, которыми получен прогноз; в ответе перечислены все использованные
+const (
+	MethodObserved   = "observed"
+	MethodRegression = "regression"
+	MethodGlobal     = "global"
+	MethodASN        = "asn"
+	MethodPrefix     = "prefix"
+)
+
+// Config: PriorASN и PriorPrefix — сколько "виртуальных" наблюдений весит
+// базовая оценка против поправки по ASN и медианы префикса. Чем их больше,
+// тем больше данных ASN/префикса нужно, чтобы они перевесили регрессию.
+type Config struct {
+	PriorASN    float64
+	PriorPrefix float64
+	FiberFactor float64
+	GeoTTL      time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{PriorASN: 10, PriorPrefix: 3, FiberFactor: 0.67, GeoTTL: time.Hour}
+}
+
+// Fit — RTT = Intercept + Slope*distance по свежим записям, плюс
+// распределение остатков для границ и медианный остаток по каждому ASN
+type Fit struct {
+	Intercept float64   `json:"intercept_ms"`
+	Slope     float64   `json:"slope_ms_per_km"`
+	Samples   int       `json:"samples"`
+	ResidP05  float64   `json:"residual_p05_ms"`
+	ResidP95  float64   `json:"residual_p95_ms"`
+	GlobalP05 float64   `json:"global_p05_ms"`
+	GlobalP50 float64   `json:"global_median_ms"`
+	GlobalP95 float64   `json:"global_p95_ms"`
+	ASNs      int       `json:"asns"`
+	FittedAt  time.Time `json:"fitted_at"`
+
+	asnResid map[int]asnStat
+}
+
+type asnStat struct {
+	median float64
+	n      int
+}
+
+// FitRecords — МНК по (distance, rtt). При вырожденных данных (меньше двух
+// разных расстояний) наклон 0, а свободный член — медиана RTT.
+func FitRecords(recs []model.RTTRecord) *Fit {
+	f := &Fit{FittedAt: time.Now(), asnResid: make(map[int]asnStat)}
+	var all, xs, ys []float64
+	for _, rec := range recs {
+		if rec.RTT_ms <= 0 {
+			continue
+		}
+		all = append(all, rec.RTT_ms)
+		if rec.DistanceToServer != nil {
+			xs = append(xs, *rec.DistanceToServer)
+			ys = append(ys, rec.RTT_ms)
+		}
+	}
+	if len(all) > 0 {
+		f.GlobalP05 = utils.Quantile(all, 0.05)
+		f.GlobalP50 = utils.Median(all)
+		f.GlobalP95 = utils.Quantile(all, 0.95)
+	}
+	f.Samples = len(xs)
+	if len(xs) == 0 {
+		return f
+	}
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= float64(len(xs))
+	my /= float64(len(xs))
+	var sxy, sxx float64
+	for i := range xs {
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if sxx > 0 {
+		f.Slope = sxy / sxx
+		f.Intercept = my - f.Slope*mx
+	} else {
+		f.Intercept = utils.Median(ys)
+	}
+
+	resid := make([]float64, len(xs))
+	for i := range xs {
+		resid[i] = ys[i] - f.Line(xs[i])
+	}
+	f.ResidP05 = utils.Quantile(resid, 0.05)
+	f.ResidP95 = utils.Quantile(resid, 0.95)
+
+	byASN := make(map[int][]float64)
+	for _, rec := range recs {
+		if rec.ASN != 0 && rec.RTT_ms > 0 && rec.DistanceToServer != nil {
+			byASN[rec.ASN] = append(byASN[rec.ASN], rec.RTT_ms-f.Line(*rec.DistanceToServer))
+		}
+	}
+	for a, rs := range byASN {
+		f.asnResid[a] = asnStat{median: utils.Median(rs), n: len(rs)}
+	}
+	f.ASNs = len(byASN)
+	return f
+}
+
+func (f *Fit) Line(distanceKm float64) float64 {
+	return f.Intercept + f.Slope*distanceKm
+}
+
+// Prediction — ответ /rtt/predict
+type Prediction struct {
+	IP         string     `json:"ip"`
+	RTTms      float64    `json:"rtt_ms"`
+	LowMs      float64    `json:"low_ms"`
+	HighMs     float64    `json:"high_ms"`
+	Method     string     `json:"method"`
+	Methods    []string   `json:"methods"`
+	Geo        *model.Geo `json:"geo,omitempty"`
+	DistanceKm *float64   `json:"distance_km,omitempty"`
+	ASN        int        `json:"asn,omitempty"`
+	Prefix     string     `json:"prefix,omitempty"`
+	PrefixN    int        `json:"prefix_samples,omitempty"`
+	ASNN       int        `json:"asn_samples,omitempty"`
+	FittedAt   time.Time  `json:"fitted_at"`
+}
+
+// GeoFunc — геолокация ip, которого нет в хранилище
+type GeoFunc func(ctx context.Context, ip string) (*model.Geo, error)
+
+type geoEntry struct {
+	geo *model.Geo
+	at  time.Time
+}
+
+const geoCacheMax = 10000
+
+type Predictor struct {
+	cfg   Config
+	store *cache.Store
+	asns  *asn.DB
+	geo   GeoFunc
+	fit   atomic.Pointer[Fit]
+
+	mu       sync.Mutex
+	geoCache map[string]geoEntry
+}
+
+// New: asns и geo могут быть nil — тогда прогноз обходится без них
+func New(cfg Config, store *cache.Store, asns *asn.DB, geo GeoFunc) *Predictor {
+	p := &Predictor{cfg: cfg, store: store, asns: asns, geo: geo, geoCache: make(map[string]geoEntry)}
+	p.fit.Store(FitRecords(nil))
+	return p
+}
+
+func (p *Predictor) Fit() *Fit { return p.fit.Load() }
+
+func (p *Predictor) Refit() {
+	p.fit.Store(FitRecords(p.store.AllFresh()))
+}
+
+// Run переобучает регрессию раз в every
+func (p *Predictor) Run(every time.Duration) {
+	p.Refit()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for range t.C {
+		p.Refit()
+	}
+}
+
+// Predict: уже измеренный ip отдаётся как есть. Иначе база — регрессия
+// по расстоянию (или глобальная медиана без геолокации), к ней
+// добавляется сжатая поправка ASN, и всё это смешивается с медианой
+// /24 (/48), если там есть клиенты.
+func (p *Predictor) Predict(ctx context.Context, addr netip.Addr) Prediction {
+	addr = addr.Unmap()
+	ip := addr.String()
+	f := p.Fit()
+	out := Prediction{IP: ip, FittedAt: f.FittedAt}
+
+	if rec, ok := p.store.Get(ip); ok {
+		out.RTTms = rec.RTT_ms
+		out.LowMs = math.Max(rec.RTT_ms-rec.RTTVar_ms, 0)
+		out.HighMs = rec.RTT_ms + rec.RTTVar_ms
+		out.Method, out.Methods = MethodObserved, []string{MethodObserved}
+		out.Geo, out.DistanceKm, out.ASN = rec.Geo, rec.DistanceToServer, rec.ASN
+		return out
+	}
+
+	var minRTT float64
+	out.Geo = p.lookupGeo(ctx, ip)
+	if out.Geo != nil && f.Samples > 0 {
+		d := utils.Haversine(utils.ServerLat, utils.ServerLon, out.Geo.Latitude, out.Geo.Longitude)
+		out.DistanceKm = &d
+		minRTT = utils.MinRTTms(d, p.cfg.FiberFactor)
+		out.RTTms = f.Line(d)
+		out.LowMs, out.HighMs = out.RTTms+f.ResidP05, out.RTTms+f.ResidP95
+		out.Methods = append(out.Methods, MethodRegression)
+	} else {
+		out.RTTms, out.LowMs, out.HighMs = f.GlobalP50, f.GlobalP05, f.GlobalP95
+		out.Methods = append(out.Methods, MethodGlobal)
+	}
+
+	if info, ok := p.asns.Lookup(ip); ok {
+		out.ASN = info.ASN
+		if st, ok := f.asnResid[info.ASN]; ok && out.DistanceKm != nil {
+			shift := st.median * float64(st.n) / (float64(st.n) + p.cfg.PriorASN)
+			out.RTTms += shift
+			out.LowMs += shift
+			out.HighMs += shift
+			out.ASNN = st.n
+			out.Methods = append(out.Methods, MethodASN)
+		}
+	}
+
+	if agg, ok, _ := p.store.PrefixAggregate(netip.PrefixFrom(addr, addr.BitLen())); ok {
+		w := float64(agg.Count) / (float64(agg.Count) + p.cfg.PriorPrefix)
+		out.RTTms = w*agg.RTTMedianMs + (1-w)*out.RTTms
+		out.LowMs = w*agg.RTTP05Ms + (1-w)*out.LowMs
+		out.HighMs = w*agg.RTTP95Ms + (1-w)*out.HighMs
+		out.Prefix, out.PrefixN = agg.Prefix, agg.Count
+		out.Methods = append(out.Methods, MethodPrefix)
+	}
+
+	// ниже физического минимума по волокну быть не может
+	out.RTTms = math.Max(out.RTTms, minRTT)
+	out.LowMs = math.Max(math.Min(out.LowMs, out.RTTms), minRTT)
+	out.HighMs = math.Max(out.HighMs, out.RTTms)
+	// основной способ — самый точный из использованных
+	out.Method = out.Methods[len(out.Methods)-1]
+	return out
+}
+
+func (p *Predictor) lookupGeo(ctx context.Context, ip string) *model.Geo {
+	if p.geo == nil {
+		return nil
+	}
+	p.mu.Lock()
+	e, ok := p.geoCache[ip]
+	p.mu.Unlock()
+	if ok && time.Since(e.at) < p.cfg.GeoTTL {
+		return e.geo
+	}
+	g, err := p.geo(ctx, ip)
+	if err != nil {
+		// неудача не кэшируется: апстрим мог быть временно недоступен
+		return nil
+	}
+	p.mu.Lock()
+	if len(p.geoCache) >= geoCacheMax {
+		p.geoCache = make(map[string]geoEntry)
+	}
+	p.geoCache[ip] = geoEntry{geo: g, at: time.Now()}
+	p.mu.Unlock()
+	return g
+}
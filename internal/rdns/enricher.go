@@ -0,0 +1,125 @@
+package rdns
+
+import (
+	"RTTServer/internal/client"
+	"RTTServer/internal/enrich"
+	"RTTServer/internal/model"
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+const Name = "rdns"
+
+// Enricher проставляет PTR клиенту и хопам трассировок Globalping.
+// PTR клиента от globalping не зависит, поэтому зависимость мягкая: порядок
+// в конвейере проверяется, а после повтора globalping этап перезапускается.
+//
+// Если все адреса есть в кэше резолвера, PTR проставляются сразу; иначе
+// этап откладывается и запросы идут в фоне, после сохранения записи.
+type Enricher struct {
+	R           *Resolver
+	Concurrency int
+}
+
+func (e *Enricher) Name() string           { return Name }
+func (e *Enricher) Dependencies() []string { return nil }
+
+func (e *Enricher) SoftDependencies() []string { return []string{model.StageGlobalping} }
+
+func (e *Enricher) Enrich(_ context.Context, rec *model.RTTRecord) error {
+	ips := addresses(rec)
+	found := make(map[string]entry, len(ips))
+	for _, ip := range ips {
+		c, ok := e.R.cached(ip)
+		if !ok {
+			return enrich.Defer()
+		}
+		found[ip] = c
+	}
+	return apply(rec, found)
+}
+
+func (e *Enricher) Background(ctx context.Context, rec model.RTTRecord) func(*model.RTTRecord) {
+	found := e.resolve(ctx, addresses(&rec))
+	return func(cur *model.RTTRecord) {
+		if err := apply(cur, found); err != nil {
+			log.Printf("enrich %s %s: %v", Name, cur.IP, err)
+			cur.SetStage(Name, model.StatusFailed, Name, err)
+			return
+		}
+		cur.SetStage(Name, model.StatusOK, Name, nil)
+	}
+}
+
+// addresses — ip клиента и хопы без PTR
+func addresses(rec *model.RTTRecord) []string {
+	ips := []string{rec.IP}
+	seen := map[string]bool{rec.IP: true}
+	for _, p := range rec.InfoProbes {
+		for _, h := range p.Hops {
+			if h.Address != "" && h.PTR == "" && !seen[h.Address] {
+				seen[h.Address] = true
+				ips = append(ips, h.Address)
+			}
+		}
+	}
+	return ips
+}
+
+func (e *Enricher) resolve(ctx context.Context, ips []string) map[string]entry {
+	n := e.Concurrency
+	if n <= 0 {
+		n = 8
+	}
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		found = make(map[string]entry, len(ips))
+		sem   = make(chan struct{}, n)
+	)
+	for _, ip := range ips {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, err := e.R.Lookup(ctx, ip)
+			mu.Lock()
+			found[ip] = entry{res: res, err: err}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return found
+}
+
+// apply проставляет найденные PTR; ошибка — только неудача по ip клиента
+func apply(rec *model.RTTRecord, found map[string]entry) error {
+	c := found[rec.IP]
+	if c.err == nil {
+		rec.PTR, rec.PTRConfirmed = c.res.Name, c.res.Confirmed
+	}
+
+	// пробы могут быть общими с предыдущей записью в store — правим копию
+	probes := make([]client.ProbeInfo, len(rec.InfoProbes))
+	for i, p := range rec.InfoProbes {
+		p.Hops = append([]client.Hop(nil), p.Hops...)
+		for j := range p.Hops {
+			h := &p.Hops[j]
+			if r, ok := found[h.Address]; ok && r.err == nil && h.PTR == "" {
+				h.PTR, h.PTRConfirmed = r.res.Name, r.res.Confirmed
+			}
+		}
+		probes[i] = p
+	}
+	if rec.InfoProbes != nil {
+		rec.InfoProbes = probes
+	}
+
+	if c.err != nil && !errors.Is(c.err, ErrNoPTR) {
+		return c.err
+	}
+	return nil
+}
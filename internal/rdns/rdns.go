@@ -0,0 +1,152 @@
+package rdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Resolver string // host:port, пусто — системный резолвер
+	Timeout  time.Duration
+	TTL      time.Duration
+	// MaxEntries — предел размера кэша; 0 — DefaultMaxEntries
+	MaxEntries int
+}
+
+const DefaultMaxEntries = 10000
+
+type Result struct {
+	Name      string `json:"name"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+type entry struct {
+	res     Result
+	err     error
+	expires time.Time
+}
+
+// Resolver делает PTR-запросы с forward-подтверждением и кэширует на TTL
+// ответы DNS, в том числе отрицательные (NXDOMAIN, пустой ответ). Таймауты
+// и временные ошибки не кэшируются.
+type Resolver struct {
+	cfg Config
+	r   *net.Resolver
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+func New(cfg Config) *Resolver {
+	r := net.DefaultResolver
+	if cfg.Resolver != "" {
+		addr := cfg.Resolver
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		d := net.Dialer{Timeout: cfg.Timeout}
+		r = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+	return &Resolver{cfg: cfg, r: r, cache: make(map[string]entry)}
+}
+
+var ErrNoPTR = errors.New("no ptr record")
+
+func (r *Resolver) Lookup(ctx context.Context, ip string) (Result, error) {
+	if e, ok := r.cached(ip); ok {
+		return e.res, e.err
+	}
+	now := time.Now()
+	res, cacheable, err := r.lookup(ctx, ip)
+	if cacheable {
+		r.mu.Lock()
+		r.cache[ip] = entry{res: res, err: err, expires: now.Add(r.cfg.TTL)}
+		if limit := r.maxEntries(); len(r.cache) > limit {
+			r.evictLocked(now, limit)
+		}
+		r.mu.Unlock()
+	}
+	return res, err
+}
+
+func (r *Resolver) maxEntries() int {
+	if r.cfg.MaxEntries > 0 {
+		return r.cfg.MaxEntries
+	}
+	return DefaultMaxEntries
+}
+
+// evictLocked убирает протухшие записи, а если их мало — случайные (порядок
+// обхода map), пока кэш не станет на десятую часть меньше предела: иначе
+// полный кэш чистился бы на каждой вставке. Вызывать под r.mu.
+func (r *Resolver) evictLocked(now time.Time, limit int) {
+	for k, v := range r.cache {
+		if now.After(v.expires) {
+			delete(r.cache, k)
+		}
+	}
+	target := limit - limit/10
+	for k := range r.cache {
+		if len(r.cache) <= target {
+			break
+		}
+		delete(r.cache, k)
+	}
+}
+
+// cached — ответ из кэша без запроса к DNS
+func (r *Resolver) cached(ip string) (entry, bool) {
+	r.mu.Lock()
+	e, ok := r.cache[ip]
+	r.mu.Unlock()
+	return e, ok && time.Now().Before(e.expires)
+}
+
+// lookup: cacheable — получен ответ DNS, а не таймаут или временная ошибка
+func (r *Resolver) lookup(ctx context.Context, ip string) (Result, bool, error) {
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+	names, err := r.r.LookupAddr(ctx, ip)
+	if err != nil {
+		if notFound(err) {
+			return Result{}, true, ErrNoPTR
+		}
+		return Result{}, false, err
+	}
+	if len(names) == 0 {
+		return Result{}, true, ErrNoPTR
+	}
+	res := Result{Name: strings.TrimSuffix(names[0], ".")}
+
+	// forward-подтверждение: имя должно резолвиться обратно в тот же ip
+	want := net.ParseIP(ip)
+	addrs, err := r.r.LookupIPAddr(ctx, res.Name)
+	if err != nil {
+		// без ответа на forward-запрос неподтверждённость не окончательна
+		return res, notFound(err), nil
+	}
+	for _, a := range addrs {
+		if a.IP.Equal(want) {
+			res.Confirmed = true
+			break
+		}
+	}
+	return res, true, nil
+}
+
+func notFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound && !dnsErr.IsTimeout && !dnsErr.IsTemporary
+}
@@ -0,0 +1,20 @@
+package reputation
+
+import (
+	"RTTServer/internal/model"
+	"context"
+)
+
+const Name = "reputation"
+
+type Enricher struct {
+	DB *DB
+}
+
+func (e *Enricher) Name() string           { return Name }
+func (e *Enricher) Dependencies() []string { return nil }
+
+func (e *Enricher) Enrich(_ context.Context, rec *model.RTTRecord) error {
+	rec.Reputation = e.DB.Match(rec.IP)
+	return nil
+}
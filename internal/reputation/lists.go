@@ -0,0 +1,93 @@
+package reputation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// форматы файлов списков
+const (
+	FormatCIDR = "cidr" // CIDR или ip на строку, комментарии через # или ;
+	FormatDROP = "drop" // Spamhaus DROP/EDROP: "1.2.3.0/24 ; SBL123"
+	FormatTor  = "tor"  // список выходных нод Tor: ip на строку или exit-addresses ("ExitAddress 1.2.3.4 ...")
+)
+
+type ListSpec struct {
+	Name   string
+	Format string
+	Path   string
+}
+
+// ParseSpecs разбирает "name=format:path" (формат можно опустить — cidr)
+func ParseSpecs(items []string) ([]ListSpec, error) {
+	out := make([]ListSpec, 0, len(items))
+	for _, it := range items {
+		name, rest, ok := strings.Cut(it, "=")
+		if !ok || name == "" || rest == "" {
+			return nil, fmt.Errorf("reputation list %q: want name=format:path", it)
+		}
+		spec := ListSpec{Name: name, Format: FormatCIDR, Path: rest}
+		if f, p, ok := strings.Cut(rest, ":"); ok {
+			switch f {
+			case FormatCIDR, FormatDROP, FormatTor:
+				spec.Format, spec.Path = f, p
+			}
+		}
+		out = append(out, spec)
+	}
+	return out, nil
+}
+
+func loadList(spec ListSpec) ([]netip.Prefix, error) {
+	f, err := os.Open(spec.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseList(f, spec.Format)
+}
+
+func parseList(r io.Reader, format string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if p, ok := parseLine(sc.Text(), format); ok {
+			out = append(out, p)
+		}
+	}
+	return out, sc.Err()
+}
+
+func parseLine(line, format string) (netip.Prefix, bool) {
+	if i := strings.IndexAny(line, "#;"); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return netip.Prefix{}, false
+	}
+	tok := fields[0]
+	if format == FormatTor && strings.EqualFold(tok, "ExitAddress") {
+		if len(fields) < 2 {
+			return netip.Prefix{}, false
+		}
+		tok = fields[1]
+	}
+	if strings.Contains(tok, "/") {
+		p, err := netip.ParsePrefix(tok)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		return p, true
+	}
+	a, err := netip.ParseAddr(tok)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen()), true
+}
@@ -0,0 +1,98 @@
+package reputation
+
+import (
+	"log"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DB держит списки в одном дереве и перечитывает файлы при изменении mtime
+type DB struct {
+	specs []ListSpec
+	t     atomic.Pointer[trie]
+
+	mu     sync.Mutex
+	mtimes map[string]time.Time
+	counts map[string]int
+}
+
+func Open(specs []ListSpec) (*DB, error) {
+	db := &DB{specs: specs, mtimes: make(map[string]time.Time), counts: make(map[string]int)}
+	if _, err := db.reload(true); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) Match(ip string) []string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	return db.t.Load().match(addr)
+}
+
+// Counts — число префиксов по каждому списку после последней загрузки
+func (db *DB) Counts() map[string]int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	out := make(map[string]int, len(db.counts))
+	for k, v := range db.counts {
+		out[k] = v
+	}
+	return out
+}
+
+func (db *DB) Watch(every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for range t.C {
+		if changed, err := db.reload(false); err != nil {
+			log.Printf("reputation reload: %v", err)
+		} else if changed {
+			log.Printf("reputation lists reloaded: %v", db.Counts())
+		}
+	}
+}
+
+// reload пересобирает дерево, если хоть один файл изменился. При ошибке чтения
+// на старте возвращается ошибка, при фоновой перезагрузке остаётся старое дерево.
+func (db *DB) reload(initial bool) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	mtimes := make(map[string]time.Time, len(db.specs))
+	changed := initial
+	for _, s := range db.specs {
+		fi, err := os.Stat(s.Path)
+		if err != nil {
+			return false, err
+		}
+		mtimes[s.Path] = fi.ModTime()
+		if !fi.ModTime().Equal(db.mtimes[s.Path]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	t := newTrie()
+	counts := make(map[string]int, len(db.specs))
+	for _, s := range db.specs {
+		prefixes, err := loadList(s)
+		if err != nil {
+			return false, err
+		}
+		for _, p := range prefixes {
+			t.insert(p, s.Name)
+		}
+		counts[s.Name] += len(prefixes)
+	}
+	db.t.Store(t)
+	db.mtimes, db.counts = mtimes, counts
+	return true, nil
+}
@@ -0,0 +1,79 @@
+package reputation
+
+import "net/netip"
+
+// trie — бинарное префиксное дерево, в узлах хранятся имена списков
+type trie struct {
+	v4, v6 *node
+}
+
+type node struct {
+	child [2]*node
+	lists []string
+}
+
+func newTrie() *trie { return &trie{v4: &node{}, v6: &node{}} }
+
+func (t *trie) insert(p netip.Prefix, list string) {
+	p = p.Masked()
+	// match ищет ::ffff:a.b.c.d в v4, так что и 4in6-префиксы кладутся туда
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	addr := p.Addr()
+	n := t.root(addr)
+	raw := addr.AsSlice()
+	for i := 0; i < p.Bits(); i++ {
+		b := bit(raw, i)
+		if n.child[b] == nil {
+			n.child[b] = &node{}
+		}
+		n = n.child[b]
+	}
+	for _, l := range n.lists {
+		if l == list {
+			return
+		}
+	}
+	n.lists = append(n.lists, list)
+}
+
+// match возвращает имена всех списков, префиксы которых покрывают addr
+func (t *trie) match(addr netip.Addr) []string {
+	addr = addr.Unmap()
+	n := t.root(addr)
+	raw := addr.AsSlice()
+	var out []string
+	for i := 0; n != nil; i++ {
+		out = appendUnique(out, n.lists...)
+		if i == len(raw)*8 {
+			break
+		}
+		n = n.child[bit(raw, i)]
+	}
+	return out
+}
+
+func (t *trie) root(addr netip.Addr) *node {
+	if addr.Is4() {
+		return t.v4
+	}
+	return t.v6
+}
+
+func bit(raw []byte, i int) int {
+	return int(raw[i/8]>>(7-uint(i%8))) & 1
+}
+
+func appendUnique(dst []string, xs ...string) []string {
+next:
+	for _, x := range xs {
+		for _, d := range dst {
+			if d == x {
+				continue next
+			}
+		}
+		dst = append(dst, x)
+	}
+	return dst
+}
@@ -0,0 +1,191 @@
+package retry
+
+import (
+	"RTTServer/internal/model"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Policy struct {
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
+
+type Job struct {
+	IP        string    `json:"ip"`
+	Stage     string    `json:"stage"`
+	Attempts  int       `json:"attempts"`
+	NextAt    time.Time `json:"next_at"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Record — снимок записи на момент неудачи: store в памяти, и после
+	// рестарта запись восстанавливается из него, чтобы повторить этап
+	Record *model.RTTRecord `json:"record,omitempty"`
+}
+
+// Queue — очередь повторного обогащения; если задан path, сохраняется на диск
+// после каждого изменения и подхватывается при старте
+type Queue struct {
+	mu     sync.Mutex
+	path   string
+	policy Policy
+	jobs   map[string]*Job
+}
+
+func Open(path string, policy Policy) (*Queue, error) {
+	q := &Queue{path: path, policy: policy, jobs: make(map[string]*Job)}
+	if path == "" {
+		return q, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		j := jobs[i]
+		q.jobs[key(j.IP, j.Stage)] = &j
+	}
+	return q, nil
+}
+
+func key(ip, stage string) string { return ip + "|" + stage }
+
+// Add ставит этап в очередь; у уже стоящей задачи обновляется только снимок записи
+func (q *Queue) Add(rec model.RTTRecord, stage string, cause error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	k := key(rec.IP, stage)
+	// карты копируются: вызывающий может дальше менять свою запись
+	rec.Enrichment = maps.Clone(rec.Enrichment)
+	rec.Ext = maps.Clone(rec.Ext)
+	if j, ok := q.jobs[k]; ok {
+		j.Record = &rec
+		q.saveLocked()
+		return
+	}
+	now := time.Now()
+	j := &Job{IP: rec.IP, Stage: stage, CreatedAt: now, NextAt: now.Add(q.delay(0)), Record: &rec}
+	if cause != nil {
+		j.LastError = cause.Error()
+	}
+	q.jobs[k] = j
+	q.saveLocked()
+}
+
+func (q *Queue) Done(ip, stage string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	k := key(ip, stage)
+	if _, ok := q.jobs[k]; !ok {
+		return
+	}
+	delete(q.jobs, k)
+	q.saveLocked()
+}
+
+// Fail отмечает неудачную попытку; возвращает false, если лимит попыток исчерпан
+// и задача удалена
+func (q *Queue) Fail(ip, stage string, cause error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	k := key(ip, stage)
+	j, ok := q.jobs[k]
+	if !ok {
+		return false
+	}
+	j.Attempts++
+	if cause != nil {
+		j.LastError = cause.Error()
+	}
+	if q.policy.MaxAttempts > 0 && j.Attempts >= q.policy.MaxAttempts {
+		delete(q.jobs, k)
+		q.saveLocked()
+		return false
+	}
+	j.NextAt = time.Now().Add(q.delay(j.Attempts))
+	q.saveLocked()
+	return true
+}
+
+func (q *Queue) Due(now time.Time) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Job
+	for _, j := range q.jobs {
+		if !j.NextAt.After(now) {
+			out = append(out, *j)
+		}
+	}
+	sortJobs(out)
+	return out
+}
+
+// Pending — все задачи без снимков записей
+func (q *Queue) Pending() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		c := *j
+		c.Record = nil
+		out = append(out, c)
+	}
+	sortJobs(out)
+	return out
+}
+
+func sortJobs(js []Job) {
+	sort.Slice(js, func(a, b int) bool { return js[a].NextAt.Before(js[b].NextAt) })
+}
+
+// delay — экспоненциальный бэкофф BaseDelay*2^attempts, не больше MaxDelay
+func (q *Queue) delay(attempts int) time.Duration {
+	d := q.policy.BaseDelay << attempts
+	if d <= 0 || (q.policy.MaxDelay > 0 && d > q.policy.MaxDelay) {
+		d = q.policy.MaxDelay
+	}
+	return d
+}
+
+func (q *Queue) saveLocked() {
+	if q.path == "" {
+		return
+	}
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, *j)
+	}
+	sortJobs(jobs)
+	b, err := json.Marshal(jobs)
+	if err != nil {
+		log.Printf("retry queue marshal: %v", err)
+		return
+	}
+	if dir := filepath.Dir(q.path); dir != "." {
+		_ = os.MkdirAll(dir, 0o755)
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		log.Printf("retry queue save %s: %v", q.path, err)
+		return
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		log.Printf("retry queue save %s: %v", q.path, err)
+	}
+}
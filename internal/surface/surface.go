@@ -0,0 +1,229 @@
+package surface
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Config — параметры интерполяции.
+// Оценка в точке = prior + IDW-среднее остатков (наблюдение − prior) соседей
+// в радиусе RadiusKm. Prior — RTT по расстоянию до сервера:
+// Inflation*MinRTTms + OverheadMs. Сам prior участвует в среднем с весом
+// наблюдения на расстоянии PriorKm, поэтому там, где данных мало,
+// оценка стягивается к нему, а confidence падает.
+type Config struct {
+	Power          float64
+	RadiusKm       float64
+	PriorKm        float64
+	Inflation      float64
+	OverheadMs     float64
+	FiberFactor    float64
+	BaselineWeight float64 // вес baseline Globalping относительно RTT клиента
+	BinDeg         float64 // наблюдения сначала сводятся в ячейки BinDeg×BinDeg
+	CellDeg        float64 // шаг готовой сетки
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Power:          2,
+		RadiusKm:       2000,
+		PriorKm:        500,
+		Inflation:      1.5,
+		OverheadMs:     5,
+		FiberFactor:    0.67,
+		BaselineWeight: 0.5,
+		BinDeg:         0.5,
+		CellDeg:        2,
+	}
+}
+
+type observation struct {
+	lat, lon float64
+	rttMs    float64
+	residual float64
+	weight   float64
+	cosLat   float64
+}
+
+type Estimate struct {
+	Lat          float64 `json:"lat"`
+	Lon          float64 `json:"lon"`
+	RTTms        float64 `json:"rtt_ms"`
+	Confidence   float64 `json:"confidence"`
+	PriorMs      float64 `json:"prior_ms"`
+	MinRTTms     float64 `json:"min_rtt_ms"`
+	DistanceKm   float64 `json:"distance_km"`
+	Observations int     `json:"observations"`
+}
+
+// Model — снимок наблюдений и посчитанная по нему сетка; неизменяем после Build
+type Model struct {
+	cfg     Config
+	obs     []observation
+	Samples int
+	BuiltAt time.Time
+	Grid    []Estimate
+}
+
+// Build сводит записи в наблюдения и считает сетку
+func Build(cfg Config, recs []model.RTTRecord) *Model {
+	m := &Model{cfg: cfg, BuiltAt: time.Now()}
+	type binKey struct {
+		y, x     int
+		baseline bool
+	}
+	bins := make(map[binKey][]float64)
+	coords := make(map[binKey][2]float64)
+	add := func(k binKey, lat, lon, rtt float64) {
+		bins[k] = append(bins[k], rtt)
+		c := coords[k]
+		n := float64(len(bins[k]))
+		// центр ячейки — среднее координат её клиентов
+		coords[k] = [2]float64{c[0] + (lat-c[0])/n, c[1] + (lon-c[1])/n}
+	}
+	for _, rec := range recs {
+		if rec.Geo == nil || (rec.Geo.Latitude == 0 && rec.Geo.Longitude == 0) {
+			continue
+		}
+		lat, lon := rec.Geo.Latitude, rec.Geo.Longitude
+		k := binKey{int(math.Floor(lat / cfg.BinDeg)), int(math.Floor(lon / cfg.BinDeg)), false}
+		if rec.RTT_ms > 0 {
+			add(k, lat, lon, rec.RTT_ms)
+			m.Samples++
+		}
+		if rec.GlobalpingRTT > 0 && cfg.BaselineWeight > 0 {
+			k.baseline = true
+			add(k, lat, lon, rec.GlobalpingRTT)
+			m.Samples++
+		}
+	}
+	for k, xs := range bins {
+		c := coords[k]
+		o := observation{lat: c[0], lon: c[1], rttMs: utils.Median(xs), weight: float64(len(xs)),
+			cosLat: math.Cos(c[0] * math.Pi / 180)}
+		if k.baseline {
+			o.weight *= cfg.BaselineWeight
+		}
+		o.residual = o.rttMs - m.prior(o.lat, o.lon)
+		m.obs = append(m.obs, o)
+	}
+	// по широте, чтобы Estimate брал только полосу ±RadiusKm
+	sort.Slice(m.obs, func(i, j int) bool { return m.obs[i].lat < m.obs[j].lat })
+	m.Grid = m.grid()
+	return m
+}
+
+func (m *Model) CellDeg() float64 { return m.cfg.CellDeg }
+
+func (m *Model) distanceKm(lat, lon float64) float64 {
+	return utils.Haversine(utils.ServerLat, utils.ServerLon, lat, lon)
+}
+
+func (m *Model) prior(lat, lon float64) float64 {
+	return m.cfg.Inflation*utils.MinRTTms(m.distanceKm(lat, lon), m.cfg.FiberFactor) + m.cfg.OverheadMs
+}
+
+func (m *Model) Estimate(lat, lon float64) Estimate {
+	d := m.distanceKm(lat, lon)
+	e := Estimate{
+		Lat: lat, Lon: lon, DistanceKm: d,
+		MinRTTms: utils.MinRTTms(d, m.cfg.FiberFactor),
+		PriorMs:  m.prior(lat, lon),
+	}
+	// градус широты ≈ 111 км, долготы — 111*cos(lat): дальние наблюдения
+	// отбрасываются без haversine (по долготе с запасом 10%)
+	maxDLat := m.cfg.RadiusKm / 111
+	cosLat := math.Cos(lat * math.Pi / 180)
+	w0 := 1 / math.Pow(m.cfg.PriorKm, m.cfg.Power)
+	var sumW, sumWR float64
+	i := sort.Search(len(m.obs), func(i int) bool { return m.obs[i].lat >= lat-maxDLat })
+	for _, o := range m.obs[i:] {
+		if o.lat > lat+maxDLat {
+			break
+		}
+		dLon := math.Abs(o.lon - lon)
+		if dLon > 180 {
+			dLon = 360 - dLon
+		}
+		if dLon*111*math.Min(cosLat, o.cosLat) > m.cfg.RadiusKm*1.1 {
+			continue
+		}
+		dist := utils.Haversine(lat, lon, o.lat, o.lon)
+		if dist > m.cfg.RadiusKm {
+			continue
+		}
+		// ближе 10 км вес не растёт, чтобы одна точка не давала confidence 1
+		w := o.weight / math.Pow(math.Max(dist, 10), m.cfg.Power)
+		sumW += w
+		sumWR += w * o.residual
+		e.Observations++
+	}
+	e.RTTms = math.Max(e.PriorMs+sumWR/(sumW+w0), e.MinRTTms)
+	e.Confidence = sumW / (sumW + w0)
+	return e
+}
+
+// grid — оценки в центрах ячеек CellDeg, где в радиусе есть хоть одно наблюдение
+func (m *Model) grid() []Estimate {
+	step := m.cfg.CellDeg
+	if step <= 0 || len(m.obs) == 0 {
+		return nil
+	}
+	var out []Estimate
+	for lat := -90 + step/2; lat < 90; lat += step {
+		for lon := -180 + step/2; lon < 180; lon += step {
+			if e := m.Estimate(lat, lon); e.Observations > 0 {
+				out = append(out, e)
+			}
+		}
+	}
+	return out
+}
+
+// Surface держит последнюю модель и пересобирает её по расписанию
+type Surface struct {
+	cfg Config
+	cur atomic.Pointer[Model]
+}
+
+// New проверяет cfg: при PriorKm == 0 вес prior бесконечен и вся сетка
+// выходит NaN, при нулевом шаге сетку не построить
+func New(cfg Config) (*Surface, error) {
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{
+		{"PriorKm", cfg.PriorKm},
+		{"RadiusKm", cfg.RadiusKm},
+		{"BinDeg", cfg.BinDeg},
+		{"CellDeg", cfg.CellDeg},
+	} {
+		if !(f.v > 0) {
+			return nil, fmt.Errorf("surface: %s must be positive, got %v", f.name, f.v)
+		}
+	}
+	s := &Surface{cfg: cfg}
+	s.cur.Store(Build(cfg, nil))
+	return s, nil
+}
+
+func (s *Surface) Current() *Model { return s.cur.Load() }
+
+func (s *Surface) Rebuild(store *cache.Store) {
+	s.cur.Store(Build(s.cfg, store.AllFresh()))
+}
+
+func (s *Surface) Run(store *cache.Store, every time.Duration) {
+	s.Rebuild(store)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for range t.C {
+		s.Rebuild(store)
+	}
+}
@@ -0,0 +1,52 @@
+package tcp
+
+import (
+	"RTTServer/internal/asn"
+	"RTTServer/internal/client"
+)
+
+var asnDB *asn.DB
+
+func SetASNDB(db *asn.DB) { asnDB = db }
+
+// annotateASN проставляет ASN хопам каждой пробы, строит AS-путь и сравнивает
+// его с путём той же пробы из предыдущего обновления
+func annotateASN(probes []client.ProbeInfo, prev []client.ProbeInfo) {
+	if asnDB == nil {
+		return
+	}
+	prevProbes := make(map[string]client.ProbeInfo, len(prev))
+	for _, p := range prev {
+		if p.ID != "" && len(p.ASPath) > 0 {
+			prevProbes[p.ID] = p
+		}
+	}
+
+	for i := range probes {
+		p := &probes[i]
+		asns := make([]int, 0, len(p.Hops))
+		for j := range p.Hops {
+			h := &p.Hops[j]
+			if info, ok := asnDB.Lookup(h.Address); ok {
+				h.ASN = info.ASN
+				h.ASOrg = info.Org
+			}
+			asns = append(asns, h.ASN)
+		}
+		p.ASPath = asn.Path(asns)
+		// проба, сменившая ASN, смотрит из другой сети — её путь не сравним
+		old, ok := prevProbes[p.ID]
+		if ok && old.ASN == p.ASN && len(p.ASPath) > 0 && !asn.EqualPath(old.ASPath, p.ASPath) {
+			p.ASPathChanged = true
+		}
+	}
+}
+
+func asPathChanged(probes []client.ProbeInfo) bool {
+	for _, p := range probes {
+		if p.ASPathChanged {
+			return true
+		}
+	}
+	return false
+}
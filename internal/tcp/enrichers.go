@@ -0,0 +1,176 @@
+package tcp
+
+import (
+	"RTTServer/internal/baseline"
+	"RTTServer/internal/client"
+	"RTTServer/internal/enrich"
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"context"
+	"errors"
+	"math"
+	"sync"
+)
+
+var baselineCfg = baseline.DefaultConfig()
+
+func SetBaselineConfig(cfg baseline.Config) { baselineCfg = cfg }
+
+var fiberFactor = 0.67
+
+func SetFiberFactor(f float64) { fiberFactor = f }
+
+// RegisterBuiltins регистрирует встроенные этапы: geo (ip-api + расстояние),
+// asn (ip2asn), globalping и feasibility
+func RegisterBuiltins(r *enrich.Registry) error {
+	for _, e := range []enrich.Enricher{geoEnricher{}, asnEnricher{}, globalpingEnricher{}, feasibilityEnricher{}} {
+		if err := r.Register(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	pipelineMu sync.RWMutex
+	pipeline   *enrich.Pipeline
+)
+
+func SetPipeline(p *enrich.Pipeline) {
+	pipelineMu.Lock()
+	pipeline = p
+	pipelineMu.Unlock()
+}
+
+func currentPipeline() *enrich.Pipeline {
+	pipelineMu.RLock()
+	p := pipeline
+	pipelineMu.RUnlock()
+	if p != nil {
+		return p
+	}
+	r := enrich.NewRegistry()
+	_ = RegisterBuiltins(r)
+	p, _ = r.Pipeline(nil, 0)
+	SetPipeline(p)
+	return p
+}
+
+type geoEnricher struct{}
+
+func (geoEnricher) Name() string           { return model.StageGeo }
+func (geoEnricher) Dependencies() []string { return nil }
+func (geoEnricher) Retryable() bool        { return true }
+
+func (geoEnricher) Enrich(ctx context.Context, rec *model.RTTRecord) error {
+	country, region, city, lat, lon, err := client.ClientIPAPI(ctx, rec.IP)
+	if err != nil {
+		rec.SetStage(model.StageGeo, model.StatusFailed, "ip-api", err)
+		rec.SetStage(model.StageDistance, model.StatusSkipped, "", errors.New("no geolocation"))
+		return err
+	}
+	rec.Geo = &model.Geo{Country: country, Region: region, City: city, Latitude: lat, Longitude: lon}
+	rec.SetStage(model.StageGeo, model.StatusOK, "ip-api", nil)
+
+	d := utils.Haversine(utils.ServerLat, utils.ServerLon, lat, lon)
+	rec.DistanceToServer = &d
+	rec.SetStage(model.StageDistance, model.StatusOK, "haversine", nil)
+	return nil
+}
+
+type asnEnricher struct{}
+
+func (asnEnricher) Name() string           { return model.StageASN }
+func (asnEnricher) Dependencies() []string { return nil }
+
+func (asnEnricher) Enrich(_ context.Context, rec *model.RTTRecord) error {
+	if asnDB == nil {
+		return enrich.Skip("asn db not configured")
+	}
+	info, ok := asnDB.Lookup(rec.IP)
+	if !ok {
+		rec.SetStage(model.StageASN, model.StatusFailed, "ip2asn", errors.New("no matching range"))
+		return nil
+	}
+	rec.ASN, rec.ASOrg = info.ASN, info.Org
+	rec.SetStage(model.StageASN, model.StatusOK, "ip2asn", nil)
+	return nil
+}
+
+type globalpingEnricher struct{}
+
+func (globalpingEnricher) Name() string           { return model.StageGlobalping }
+func (globalpingEnricher) Dependencies() []string { return []string{model.StageGeo} }
+func (globalpingEnricher) Retryable() bool        { return true }
+
+func (globalpingEnricher) Enrich(ctx context.Context, rec *model.RTTRecord) error {
+	prev, hasPrev := enrich.Previous(ctx)
+	// повтор из очереди идёт мимо гейта
+	if !enrich.IsRetry(ctx) && !gpGate.Allow(rec.IP, globalpingIPTTL) {
+		if !carryGlobalping(rec, prev, hasPrev) {
+			return enrich.Skip("rate limited")
+		}
+		return nil
+	}
+
+	agg, err := client.ClientGlobalping(ctx, rec.Geo.Country, rec.Geo.Region, rec.Geo.City)
+	if err != nil {
+		carryGlobalping(rec, prev, hasPrev)
+		rec.SetStage(model.StageGlobalping, model.StatusFailed, "globalping", err)
+		return err
+	}
+	if rawStore != nil {
+		rawStore.Put(agg.MeasurementID, agg.Raw)
+	}
+	var prevProbes []client.ProbeInfo
+	if hasPrev {
+		prevProbes = prev.InfoProbes
+	}
+	annotateASN(agg.Probes, prevProbes)
+	b := baseline.Aggregate(baselineCfg, agg.Probes, rec.Geo.Latitude, rec.Geo.Longitude, true)
+
+	rec.IDProbeGlabal = agg.MeasurementID
+	rec.GlobalpingRTT = b.RTTms
+	rec.GlobalpingConfidence = b.Confidence
+	rec.InfoProbes = agg.Probes
+	rec.ASPathChanged = asPathChanged(agg.Probes)
+	rec.SetStage(model.StageGlobalping, model.StatusOK, "globalping", nil)
+	return nil
+}
+
+// carryGlobalping переносит результат Globalping из предыдущей записи,
+// пока новый не получен; false — переносить нечего
+func carryGlobalping(rec *model.RTTRecord, prev model.RTTRecord, hasPrev bool) bool {
+	if !hasPrev || prev.IDProbeGlabal == "" {
+		return false
+	}
+	rec.IDProbeGlabal = prev.IDProbeGlabal
+	rec.GlobalpingRTT = prev.GlobalpingRTT
+	rec.GlobalpingConfidence = prev.GlobalpingConfidence
+	rec.InfoProbes = prev.InfoProbes
+	rec.ASPathChanged = prev.ASPathChanged
+	if st, ok := prev.Enrichment[model.StageGlobalping]; ok && st.Status != model.StatusPending {
+		rec.Enrichment[model.StageGlobalping] = st
+	}
+	return true
+}
+
+type feasibilityEnricher struct{}
+
+func (feasibilityEnricher) Name() string           { return model.StageFeasibility }
+func (feasibilityEnricher) Dependencies() []string { return []string{model.StageGeo} }
+
+func (feasibilityEnricher) Enrich(_ context.Context, rec *model.RTTRecord) error {
+	if rec.DistanceToServer == nil {
+		return enrich.Skip("no distance")
+	}
+	if rec.RTT_ms <= 0 {
+		return enrich.Skip("no tcp rtt")
+	}
+	minRTT := utils.MinRTTms(*rec.DistanceToServer, fiberFactor)
+	rec.Feasibility = &model.Feasibility{
+		Feasible: rec.RTT_ms >= minRTT,
+		MinRTTms: math.Round(minRTT*1000) / 1000,
+	}
+	return nil
+}
@@ -2,10 +2,13 @@ package tcp
 
 import (
 	"RTTServer/internal/cache"
-	"RTTServer/internal/client"
+	"RTTServer/internal/enrich"
 	"RTTServer/internal/model"
 	"RTTServer/internal/utils"
+	"context"
 	"log"
+	"maps"
+	"math"
 	"net"
 	"sync"
 	"time"
@@ -51,53 +54,66 @@ func HandleConn(c net.Conn, store *cache.Store) {
 		log.Printf("tcp_info %s: %v", remoteIP, err)
 		return
 	}
-	country, region, city, lat, lon, err := client.ClientIPAPI(remoteIP)
-	if err != nil {
-		log.Printf("ip-api %s: %v", remoteIP, err)
-	}
-
-	distanceToServer := utils.Haversine(36.102, -115.1447, lat, lon)
-
 	prev, hasPrev := store.Get(remoteIP)
-	var agg client.GlobalpingAgg
-
-	if gpGate.Allow(remoteIP, globalpingIPTTL) {
-		res, err := client.ClientGlobalping(country, region, city)
-		if err != nil {
-			log.Printf("globalping %s: %v", remoteIP, err)
-			if hasPrev {
-				agg.MeasurementID = prev.IDProbeGlabal
-				agg.RTTMedianMS = prev.GlobalpingRTT
-				agg.Probes = prev.InfoProbes
-				agg.RawOutputs = prev.Rawdate
-			}
-		} else {
-			agg = res
-		}
-	} else if hasPrev {
-		agg.MeasurementID = prev.IDProbeGlabal
-		agg.RTTMedianMS = prev.GlobalpingRTT
-		agg.Probes = prev.InfoProbes
-		agg.RawOutputs = prev.Rawdate
+	rec := model.RTTRecord{
+		IP:          remoteIP,
+		TCPI_RTT_us: rttUS,
+		RTT_ms:      float64(rttUS) / 1000.0,
+		TCPI_VAR_us: rttVarUS,
+		RTTVar_ms:   float64(rttVarUS) / 1000.0,
+		UpdatedAt:   time.Now(),
+	}
+	if la, ok := c.LocalAddr().(*net.TCPAddr); ok {
+		rec.ListenerPort = la.Port
 	}
+	rec.MaxDistanceKm = math.Round(utils.MaxDistanceKm(rec.RTT_ms, fiberFactor))
+	p := currentPipeline()
+	p.MarkPending(&rec)
+	// RTT виден сразу, обогащение догоняет
+	store.Set(pendingRecord(rec, prev, hasPrev))
 
-	rec := model.RTTRecord{
-		IP:               remoteIP,
-		DistanceToServer: distanceToServer,
-		TCPI_RTT_us:      rttUS,
-		RTT_ms:           float64(rttUS) / 1000.0,
-		TCPI_VAR_us:      rttVarUS,
-		RTTVar_ms:        float64(rttVarUS) / 1000.0,
-		IDProbeGlabal:    agg.MeasurementID,
-		GlobalpingRTT:    agg.RTTMedianMS,
-		InfoProbes:       agg.Probes,
-		UpdatedAt:        time.Now(),
-		Rawdate:          agg.RawOutputs,
+	ctx := context.Background()
+	if hasPrev {
+		ctx = enrich.WithPrevious(ctx, prev)
 	}
+	p.Run(ctx, &rec)
+
 	store.Set(rec)
+	p.RunBackground(context.Background(), rec, store.Update)
+	scheduleRetries(p, &rec)
 	log.Printf("updated ip=%s rtt=%.3fms var=%.3fms", rec.IP, rec.RTT_ms, rec.RTTVar_ms)
 }
 
+// pendingRecord — запись до конца обогащения: прежние данные ip остаются,
+// меняются только замер и статусы этапов, чтобы читатели и подписчики
+// не видели их стёртыми. Вердикты VPN и feasibility считаются от RTT —
+// рядом с новым замером старые были бы неверны, поэтому они сбрасываются
+func pendingRecord(rec, prev model.RTTRecord, hasPrev bool) model.RTTRecord {
+	if !hasPrev {
+		return rec
+	}
+	out := prev
+	out.TCPI_RTT_us, out.RTT_ms = rec.TCPI_RTT_us, rec.RTT_ms
+	out.TCPI_VAR_us, out.RTTVar_ms = rec.TCPI_VAR_us, rec.RTTVar_ms
+	out.ListenerPort = rec.ListenerPort
+	out.MaxDistanceKm = rec.MaxDistanceKm
+	out.UpdatedAt = rec.UpdatedAt
+	out.VPN, out.Feasibility = nil, nil
+	if out.Ext != nil {
+		out.Ext = maps.Clone(out.Ext)
+		delete(out.Ext, model.StageVPN)
+		delete(out.Ext, model.StageFeasibility)
+	}
+	out.Enrichment = make(map[string]model.Stage, len(prev.Enrichment)+len(rec.Enrichment))
+	for k, v := range prev.Enrichment {
+		out.Enrichment[k] = v
+	}
+	for k, v := range rec.Enrichment {
+		out.Enrichment[k] = v
+	}
+	return out
+}
+
 func peerIP(addr net.Addr) string {
 	if addr == nil {
 		return ""
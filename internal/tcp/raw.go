@@ -0,0 +1,7 @@
+package tcp
+
+import "RTTServer/internal/cache"
+
+var rawStore *cache.RawStore
+
+func SetRawStore(s *cache.RawStore) { rawStore = s }
@@ -0,0 +1,129 @@
+package tcp
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/enrich"
+	"RTTServer/internal/model"
+	"RTTServer/internal/retry"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var retryQueue *retry.Queue
+
+func SetRetryQueue(q *retry.Queue) { retryQueue = q }
+
+func scheduleRetries(p *enrich.Pipeline, rec *model.RTTRecord) {
+	if retryQueue == nil {
+		return
+	}
+	for name, st := range rec.Enrichment {
+		if !p.Retryable(name) {
+			continue
+		}
+		switch st.Status {
+		case model.StatusFailed:
+			retryQueue.Add(*rec, name, errors.New(st.Error))
+		case model.StatusOK:
+			retryQueue.Done(rec.IP, name)
+		}
+	}
+}
+
+// RunRetries периодически повторяет обогащение из очереди и обновляет запись в store на месте
+func RunRetries(store *cache.Store, every time.Duration) {
+	if retryQueue == nil {
+		return
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for range t.C {
+		for _, j := range retryQueue.Due(time.Now()) {
+			retryJob(store, j)
+		}
+	}
+}
+
+func retryJob(store *cache.Store, j retry.Job) {
+	p := currentPipeline()
+	rec, ok := store.Get(j.IP)
+	// после рестарта store пуст — запись поднимается из снимка в задаче
+	restored := !ok && j.Record != nil && time.Since(j.Record.UpdatedAt) <= cache.TTL
+	if restored {
+		rec = *j.Record
+	}
+	if (!ok && !restored) || !p.Retryable(j.Stage) || rec.StageStatus(j.Stage) == model.StatusOK {
+		retryQueue.Done(j.IP, j.Stage)
+		return
+	}
+
+	// повторяем этап и всё, что от него зависит (geo -> globalping)
+	ctx := enrich.WithRetry(enrich.WithPrevious(context.Background(), rec))
+	ran := p.RunFrom(ctx, &rec, j.Stage)
+
+	st := rec.Enrichment[j.Stage]
+	if st.Status != model.StatusOK {
+		if restored {
+			store.Restore(rec)
+		}
+		if !retryQueue.Fail(j.IP, j.Stage, errors.New(st.Error)) {
+			log.Printf("retry %s %s: giving up after %d attempts: %s", j.IP, j.Stage, j.Attempts+1, st.Error)
+		}
+		return
+	}
+
+	var updated bool
+	if restored {
+		updated = store.Restore(rec)
+	}
+	if !updated {
+		updated = store.Update(j.IP, func(cur *model.RTTRecord) bool {
+			for _, s := range ran {
+				applyStage(cur, &rec, s)
+			}
+			return true
+		})
+	}
+	retryQueue.Done(j.IP, j.Stage)
+	if updated {
+		p.RunBackground(context.Background(), rec, store.Update)
+		log.Printf("retry %s %s: ok", j.IP, j.Stage)
+		scheduleRetries(p, &rec)
+	}
+}
+
+// applyStage копирует результат этапа из src в dst, не трогая измеренный RTT
+func applyStage(dst, src *model.RTTRecord, stage string) {
+	stages := []string{stage}
+	switch stage {
+	case model.StageGeo:
+		dst.Geo = src.Geo
+		dst.DistanceToServer = src.DistanceToServer
+		stages = append(stages, model.StageDistance)
+	case model.StageASN:
+		dst.ASN, dst.ASOrg = src.ASN, src.ASOrg
+	case model.StageGlobalping:
+		dst.IDProbeGlabal = src.IDProbeGlabal
+		dst.GlobalpingRTT = src.GlobalpingRTT
+		dst.GlobalpingConfidence = src.GlobalpingConfidence
+		dst.InfoProbes = src.InfoProbes
+		dst.ASPathChanged = src.ASPathChanged
+	case model.StageVPN:
+		dst.VPN = src.VPN
+	case model.StageFeasibility:
+		dst.Feasibility = src.Feasibility
+	}
+	if v, ok := src.Ext[stage]; ok {
+		dst.SetExt(stage, v)
+	}
+	for _, s := range stages {
+		if st, ok := src.Enrichment[s]; ok {
+			if dst.Enrichment == nil {
+				dst.Enrichment = make(map[string]model.Stage)
+			}
+			dst.Enrichment[s] = st
+		}
+	}
+}
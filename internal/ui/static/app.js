@@ -0,0 +1,430 @@
+"use strict";
+// Дашборд: обзор (карта + таблица) и страница ip (#/ip/1.2.3.4).
+// Всё берётся из /v1 API, живые обновления — из /v1/rtt/stream.
+
+// координаты сервера — те же, что utils.ServerLat/ServerLon
+const SERVER = { lat: 36.102, lon: -115.1447 };
+const API = "/v1";
+const PAGE = 100;
+
+const $ = (sel, root = document) => root.querySelector(sel);
+const esc = s => String(s ?? "").replace(/[&<>"']/g, c => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c]));
+const fmt = (v, d = 1) => (v == null || v === "" || Number.isNaN(+v)) ? "" : Number(v).toFixed(d);
+const css = name => getComputedStyle(document.documentElement).getPropertyValue(name).trim();
+const rttColor = ms => `hsl(${Math.max(0, 120 - Math.min(ms, 300) / 300 * 120)},70%,45%)`;
+const ipLink = ip => `<a href="#/ip/${encodeURIComponent(ip)}">${esc(ip)}</a>`;
+const badge = s => s ? `<span class="badge ${esc(s)}">${esc(s)}</span>` : "";
+
+function ago(t) {
+  const s = (Date.now() - new Date(t)) / 1000;
+  if (s < 60) return Math.max(0, s | 0) + "s ago";
+  if (s < 3600) return (s / 60 | 0) + "m ago";
+  if (s < 86400) return (s / 3600 | 0) + "h ago";
+  return new Date(t).toLocaleString();
+}
+
+async function getJSON(url) {
+  const r = await fetch(url);
+  if (!r.ok) {
+    let msg = "HTTP " + r.status;
+    try { msg = (await r.json()).error.message; } catch (e) { /* не JSON */ }
+    throw new Error(msg);
+  }
+  return { body: await r.json(), headers: r.headers };
+}
+
+const state = {
+  clients: new Map(), // ip -> точка карты
+  probes: [],
+  rows: [],           // загруженные строки таблицы
+  sort: "-updated_at",
+  filters: new URLSearchParams(),
+  cursor: "",
+  detailIP: null,
+};
+
+// ---------- карта ----------
+
+const map = { canvas: $("#map"), tip: $("#tip"), points: [], w: 0, h: 0, pending: false };
+
+function project(lat, lon) {
+  return [(lon + 180) / 360 * map.w, (90 - lat) / 180 * map.h];
+}
+
+function clientPoint(rec) {
+  if (!rec.geo || (!rec.geo.latitude && !rec.geo.longitude)) return null;
+  return {
+    ip: rec.ip, lat: rec.geo.latitude, lon: rec.geo.longitude, rtt: rec.tcpi_rtt_ms,
+    country: rec.geo.country, city: rec.geo.city, asn: rec.asn, as_org: rec.as_org,
+  };
+}
+
+function drawMap() {
+  map.pending = false;
+  const c = map.canvas, dpr = window.devicePixelRatio || 1;
+  if (!c.clientWidth) return;
+  map.w = c.clientWidth;
+  map.h = Math.round(map.w / 2);
+  c.style.height = map.h + "px";
+  c.width = map.w * dpr;
+  c.height = map.h * dpr;
+  const g = c.getContext("2d");
+  g.setTransform(dpr, 0, 0, dpr, 0, 0);
+
+  g.fillStyle = css("--sea");
+  g.fillRect(0, 0, map.w, map.h);
+  g.fillStyle = css("--land");
+  for (const poly of WORLD) {
+    g.beginPath();
+    poly.forEach(([lon, lat], i) => {
+      const [x, y] = project(lat, lon);
+      if (i) g.lineTo(x, y); else g.moveTo(x, y);
+    });
+    g.closePath();
+    g.fill();
+  }
+  g.strokeStyle = css("--line");
+  g.lineWidth = 0.5;
+  for (let lon = -150; lon < 180; lon += 30) {
+    const [x] = project(0, lon);
+    g.beginPath(); g.moveTo(x, 0); g.lineTo(x, map.h); g.stroke();
+  }
+  for (let lat = -60; lat < 90; lat += 30) {
+    const [, y] = project(lat, 0);
+    g.beginPath(); g.moveTo(0, y); g.lineTo(map.w, y); g.stroke();
+  }
+
+  map.points = [];
+  if ($("#show-probes").checked) {
+    g.fillStyle = "#b48cff";
+    for (const p of state.probes) {
+      const [x, y] = project(p.lat, p.lon);
+      g.fillRect(x - 2.5, y - 2.5, 5, 5);
+      map.points.push({ x, y, probe: p });
+    }
+  }
+  if ($("#show-clients").checked) {
+    for (const p of state.clients.values()) {
+      const [x, y] = project(p.lat, p.lon);
+      g.fillStyle = rttColor(p.rtt);
+      g.beginPath(); g.arc(x, y, 4, 0, 2 * Math.PI); g.fill();
+      map.points.push({ x, y, client: p });
+    }
+  }
+  const [sx, sy] = project(SERVER.lat, SERVER.lon);
+  g.fillStyle = "#fff";
+  g.strokeStyle = css("--accent");
+  g.lineWidth = 2;
+  g.beginPath(); g.arc(sx, sy, 5, 0, 2 * Math.PI); g.fill(); g.stroke();
+}
+
+// redraw склеивает частые обновления из потока в один кадр
+function redraw() {
+  if (map.pending) return;
+  map.pending = true;
+  requestAnimationFrame(drawMap);
+}
+
+function nearestPoint(ev) {
+  const rect = map.canvas.getBoundingClientRect();
+  const x = ev.clientX - rect.left, y = ev.clientY - rect.top;
+  let best = null, bestD = 64; // не дальше 8px
+  for (const p of map.points) {
+    const d = (p.x - x) ** 2 + (p.y - y) ** 2;
+    if (d <= bestD) { best = p; bestD = d; }
+  }
+  return { best, x, y };
+}
+
+map.canvas.addEventListener("mousemove", ev => {
+  const { best, x, y } = nearestPoint(ev);
+  if (!best) { map.tip.hidden = true; return; }
+  let html;
+  if (best.client) {
+    const c = best.client;
+    html = `<b>${esc(c.ip)}</b><br>${esc([c.city, c.country].filter(Boolean).join(", "))}<br>` +
+      `RTT ${fmt(c.rtt)} ms${c.asn ? `<br>AS${c.asn} ${esc(c.as_org)}` : ""}`;
+  } else {
+    const p = best.probe;
+    html = `<b>probe</b> ${esc([p.city, p.country].filter(Boolean).join(", "))}<br>` +
+      `AS${esc(p.asn)} ${esc(p.network)}<br>${p.clients} clients` +
+      (p.rtt_median_ms != null ? `, median ${fmt(p.rtt_median_ms)} ms` : "");
+  }
+  map.tip.innerHTML = html;
+  map.tip.hidden = false;
+  map.tip.style.left = Math.min(x + 12, map.w - map.tip.offsetWidth - 4) + "px";
+  map.tip.style.top = (y + 12) + "px";
+  map.canvas.style.cursor = best.client ? "pointer" : "crosshair";
+});
+map.canvas.addEventListener("mouseleave", () => { map.tip.hidden = true; });
+map.canvas.addEventListener("click", ev => {
+  const { best } = nearestPoint(ev);
+  if (best && best.client) location.hash = "#/ip/" + encodeURIComponent(best.client.ip);
+});
+$("#show-clients").addEventListener("change", redraw);
+$("#show-probes").addEventListener("change", redraw);
+window.addEventListener("resize", redraw);
+
+async function loadMap() {
+  try {
+    const { body } = await getJSON(API + "/rtt/geojson");
+    state.clients.clear();
+    state.probes = [];
+    for (const f of body.features) {
+      const [lon, lat] = f.geometry.coordinates;
+      const p = f.properties;
+      if (p.layer === "clients") {
+        state.clients.set(p.ip, { ip: p.ip, lat, lon, rtt: p.rtt_ms, country: p.country, city: p.city, asn: p.asn, as_org: p.as_org });
+      } else {
+        state.probes.push({ lat, lon, ...p });
+      }
+    }
+    $("#summary").textContent = `${state.clients.size} clients · ${state.probes.length} probes`;
+    redraw();
+  } catch (e) {
+    $("#summary").textContent = "map: " + e.message;
+  }
+}
+
+// ---------- таблица ----------
+
+function rowHTML(r) {
+  return `<td>${ipLink(r.ip)}</td>` +
+    `<td>${esc(r.geo && r.geo.country)}</td>` +
+    `<td>${esc(r.geo && r.geo.city)}</td>` +
+    `<td class="num">${r.asn ? esc(r.asn) : ""}</td>` +
+    `<td>${esc(r.network_type || r.as_org || "")}</td>` +
+    `<td class="num" style="color:${rttColor(r.tcpi_rtt_ms)}">${fmt(r.tcpi_rtt_ms)}</td>` +
+    `<td class="num">${fmt(r.tcpi_rttvar_ms)}</td>` +
+    `<td class="num">${fmt(r.globalping_rtt_ms)}</td>` +
+    `<td class="num">${fmt(r.distance_to_server_km, 0)}</td>` +
+    `<td>${r.vpn ? badge(r.vpn.verdict) : ""}</td>` +
+    `<td title="${esc(r.updated_at)}">${ago(r.updated_at)}</td>`;
+}
+
+function renderTable() {
+  const tbody = $("#table tbody");
+  tbody.innerHTML = "";
+  for (const r of state.rows) {
+    const tr = document.createElement("tr");
+    tr.dataset.ip = r.ip;
+    tr.innerHTML = rowHTML(r);
+    tbody.appendChild(tr);
+  }
+  for (const th of document.querySelectorAll("#table th[data-sort]")) {
+    const key = th.dataset.sort;
+    th.classList.toggle("asc", state.sort === key);
+    th.classList.toggle("desc", state.sort === "-" + key);
+  }
+  $("#more").hidden = !state.cursor;
+  $("#count").textContent = `${state.rows.length} shown`;
+}
+
+async function loadTable(append) {
+  const q = new URLSearchParams(state.filters);
+  q.set("sort", state.sort);
+  q.set("limit", PAGE);
+  if (append && state.cursor) q.set("cursor", state.cursor);
+  try {
+    const { body, headers } = await getJSON(API + "/rtt/all?" + q);
+    state.rows = append ? state.rows.concat(body) : body;
+    state.cursor = headers.get("X-Next-Cursor") || "";
+    renderTable();
+  } catch (e) {
+    $("#count").textContent = e.message;
+  }
+}
+
+document.querySelectorAll("#table th[data-sort]").forEach(th => th.addEventListener("click", () => {
+  const key = th.dataset.sort;
+  state.sort = state.sort === "-" + key ? key : "-" + key;
+  loadTable(false);
+}));
+$("#more").addEventListener("click", () => loadTable(true));
+$("#filters").addEventListener("submit", ev => {
+  ev.preventDefault();
+  state.filters = new URLSearchParams();
+  for (const [k, v] of new FormData(ev.target)) {
+    if (v.trim()) state.filters.set(k, v.trim());
+  }
+  loadTable(false);
+});
+
+// upsertRow: новые ip добавляются наверх только в порядке по умолчанию
+// и без фильтров — иначе место строки знает только сервер
+function upsertRow(rec) {
+  const i = state.rows.findIndex(r => r.ip === rec.ip);
+  let tr;
+  if (i >= 0) {
+    state.rows[i] = rec;
+    tr = document.querySelector(`#table tr[data-ip="${CSS.escape(rec.ip)}"]`);
+  } else if (state.sort === "-updated_at" && ![...state.filters.keys()].length) {
+    state.rows.unshift(rec);
+    tr = document.createElement("tr");
+    tr.dataset.ip = rec.ip;
+    $("#table tbody").prepend(tr);
+    $("#count").textContent = `${state.rows.length} shown`;
+  }
+  if (!tr) return;
+  tr.innerHTML = rowHTML(rec);
+  tr.classList.remove("flash");
+  void tr.offsetWidth; // перезапуск анимации
+  tr.classList.add("flash");
+}
+
+// ---------- страница ip ----------
+
+function dl(pairs) {
+  return "<dl>" + pairs.filter(([, v]) => v !== "" && v != null)
+    .map(([k, v]) => `<dt>${esc(k)}</dt><dd>${v}</dd>`).join("") + "</dl>";
+}
+
+function chart(samples) {
+  if (!samples.length) return `<p class="muted">no history</p>`;
+  const W = 800, H = 220, L = 40, R = 10, T = 10, B = 24;
+  const t0 = new Date(samples[0].at).getTime();
+  const t1 = Math.max(new Date(samples[samples.length - 1].at).getTime(), t0 + 1);
+  const ys = samples.flatMap(s => [s.rtt_ms + s.rttvar_ms, s.globalping_rtt_ms || 0]);
+  const yMax = Math.max(...ys, 1) * 1.1;
+  const x = t => L + (new Date(t).getTime() - t0) / (t1 - t0) * (W - L - R);
+  const y = v => T + (1 - v / yMax) * (H - T - B);
+  let out = `<svg class="chart" viewBox="0 0 ${W} ${H}" preserveAspectRatio="none">`;
+  for (let i = 0; i <= 4; i++) {
+    const v = yMax * i / 4;
+    out += `<line class="grid" x1="${L}" x2="${W - R}" y1="${y(v)}" y2="${y(v)}"/><text x="2" y="${y(v) + 3}">${fmt(v, 0)}</text>`;
+  }
+  out += `<text x="${L}" y="${H - 6}">${esc(new Date(t0).toLocaleString())}</text>`;
+  out += `<text x="${W - R}" y="${H - 6}" text-anchor="end">${esc(new Date(t1).toLocaleString())}</text>`;
+  const upper = samples.map(s => `${x(s.at)},${y(s.rtt_ms + s.rttvar_ms)}`);
+  const lower = samples.map(s => `${x(s.at)},${y(Math.max(s.rtt_ms - s.rttvar_ms, 0))}`).reverse();
+  out += `<polygon points="${upper.concat(lower).join(" ")}" fill="#4aa3ff22"/>`;
+  out += `<polyline points="${samples.map(s => `${x(s.at)},${y(s.rtt_ms)}`).join(" ")}" fill="none" stroke="#4aa3ff" stroke-width="2"/>`;
+  const base = samples.filter(s => s.globalping_rtt_ms);
+  if (base.length) {
+    out += `<polyline points="${base.map(s => `${x(s.at)},${y(s.globalping_rtt_ms)}`).join(" ")}" fill="none" stroke="#b48cff" stroke-dasharray="4 3"/>`;
+  }
+  for (const s of samples) {
+    out += `<circle cx="${x(s.at)}" cy="${y(s.rtt_ms)}" r="3" fill="#4aa3ff"><title>${esc(new Date(s.at).toLocaleString())}: ${fmt(s.rtt_ms, 2)} ± ${fmt(s.rttvar_ms, 2)} ms</title></circle>`;
+  }
+  return out + "</svg>";
+}
+
+function probePaths(rec) {
+  const probes = rec.info_probes || [];
+  if (!probes.length) return `<p class="muted">no Globalping measurements</p>`;
+  return probes.map(p => {
+    const hops = (p.hops || []).map(h => {
+      const name = h.ptr ? `${esc(h.ptr)}${h.ptr_confirmed ? " ✓" : ""}` : esc(h.hostname);
+      return `<tr><td class="num">${esc(h.hop)}</td><td>${esc(h.address)}</td><td>${name}</td>` +
+        `<td>${h.asn ? "AS" + esc(h.asn) + " " + esc(h.as_org) : ""}</td><td class="num">${fmt(h.rtt_ms, 2)}</td></tr>`;
+    }).join("");
+    const where = [p.city, p.country].filter(Boolean).join(", ");
+    return `<div class="probe-path"><b>${esc(where)}</b> · AS${esc(p.asn)} ${esc(p.network)} · ` +
+      `${fmt(p.rtt_ms, 2)} ms${p.client_distance_km ? ` · ${fmt(p.client_distance_km, 0)} km from client` : ""}` +
+      `${p.outlier ? ` ${badge("outlier")}` : ""}` +
+      (p.as_path && p.as_path.length ? `<div class="path-asns">AS path: ${p.as_path.map(a => "AS" + esc(a)).join(" → ")}${p.as_path_changed ? " " + badge("changed") : ""}</div>` : "") +
+      (hops ? `<table><thead><tr><th class="num">#</th><th>Address</th><th>Name</th><th>ASN</th><th class="num">RTT ms</th></tr></thead><tbody>${hops}</tbody></table>` : "") +
+      `</div>`;
+  }).join("");
+}
+
+function renderDetail(rec, history, err) {
+  const ip = state.detailIP;
+  const el = $("#detail");
+  if (!rec) {
+    el.innerHTML = `<section class="panel"><h2>${esc(ip)}</h2><p class="muted">${esc(err || "not found")}</p>` +
+      (history.length ? `<h3>History</h3>${chart(history)}` : "") + `<p><a href="#/">← back</a></p></section>`;
+    return;
+  }
+  const g = rec.geo || {};
+  const stages = Object.entries(rec.enrichment || {}).sort()
+    .map(([k, s]) => [k, `${badge(s.status)} ${esc(s.source)} ${s.error ? `<span class="muted">${esc(s.error)}</span>` : ""}`]);
+  el.innerHTML = `
+    <section class="panel"><div class="panel-head"><h2>${esc(rec.ip)}</h2>
+      <span class="muted">updated ${ago(rec.updated_at)}</span><a href="#/">← back</a></div>
+      <div class="cards">
+        <div>${dl([
+          ["RTT", `<b style="color:${rttColor(rec.tcpi_rtt_ms)}">${fmt(rec.tcpi_rtt_ms, 2)} ms</b>`],
+          ["RTT variance", fmt(rec.tcpi_rttvar_ms, 2) + " ms"],
+          ["Globalping baseline", rec.globalping_rtt_ms ? `${fmt(rec.globalping_rtt_ms, 2)} ms (confidence ${fmt(rec.globalping_confidence, 2)})` : ""],
+          ["VPN", rec.vpn ? `${badge(rec.vpn.verdict)} score ${fmt(rec.vpn.score, 2)}${(rec.vpn.reasons || []).map(r => "<br>" + esc(r)).join("")}` : ""],
+          ["Reputation", (rec.reputation || []).map(badge).join(" ")],
+        ])}</div>
+        <div><h3>TCP_INFO</h3>${dl([
+          ["tcpi_rtt", esc(rec.tcpi_rtt_us) + " µs"],
+          ["tcpi_rttvar", esc(rec.tcpi_rttvar_us) + " µs"],
+          ["listener port", esc(rec.listener_port)],
+          ["max distance", rec.max_distance_km ? fmt(rec.max_distance_km, 0) + " km" : ""],
+          ["feasible", rec.feasibility ? `${rec.feasibility.feasible ? badge("ok") : badge("failed")} min ${fmt(rec.feasibility.min_rtt_ms, 2)} ms` : ""],
+        ])}</div>
+        <div><h3>Location &amp; network</h3>${dl([
+          ["Location", esc([g.city, g.region, g.country].filter(Boolean).join(", "))],
+          ["Coordinates", g.latitude || g.longitude ? `${fmt(g.latitude, 3)}, ${fmt(g.longitude, 3)}` : ""],
+          ["Distance", rec.distance_to_server_km != null ? fmt(rec.distance_to_server_km, 0) + " km" : ""],
+          ["ASN", rec.asn ? `<a href="${API}/rtt/asn/${esc(rec.asn)}">AS${esc(rec.asn)}</a> ${esc(rec.as_org)}` : ""],
+          ["Network type", esc(rec.network_type)],
+          ["PTR", rec.ptr ? esc(rec.ptr) + (rec.ptr_confirmed ? " ✓" : "") : ""],
+        ])}</div>
+        <div><h3>Enrichment</h3>${dl(stages)}</div>
+      </div>
+    </section>
+    <section class="panel"><h3>History</h3>${chart(history)}</section>
+    <section class="panel"><h3>Traceroute paths</h3>${probePaths(rec)}</section>`;
+}
+
+async function loadDetail() {
+  const ip = state.detailIP;
+  const [rec, hist] = await Promise.allSettled([
+    getJSON(API + "/rtt?ip=" + encodeURIComponent(ip)),
+    getJSON(API + "/rtt/history?ip=" + encodeURIComponent(ip)),
+  ]);
+  if (ip !== state.detailIP) return; // пока грузили, ушли на другую страницу
+  const samples = hist.status === "fulfilled" ? hist.value.body.samples : [];
+  if (rec.status === "fulfilled") renderDetail(rec.value.body, samples);
+  else renderDetail(null, samples, rec.reason.message);
+}
+
+// ---------- маршруты и поток ----------
+
+function route() {
+  const m = location.hash.match(/^#\/ip\/(.+)$/);
+  state.detailIP = m ? decodeURIComponent(m[1]) : null;
+  $("#overview").hidden = !!state.detailIP;
+  $("#detail").hidden = !state.detailIP;
+  if (state.detailIP) {
+    $("#detail").innerHTML = `<section class="panel muted">loading…</section>`;
+    loadDetail();
+  } else {
+    redraw();
+  }
+}
+
+function onRecord(rec) {
+  const p = clientPoint(rec);
+  if (p) state.clients.set(rec.ip, p);
+  $("#summary").textContent = `${state.clients.size} clients · ${state.probes.length} probes`;
+  if (state.detailIP === rec.ip) loadDetail();
+  if (!state.detailIP) {
+    upsertRow(rec);
+    redraw();
+  }
+}
+
+function connect() {
+  const es = new EventSource(API + "/rtt/stream");
+  const live = $("#live");
+  es.onopen = () => { live.className = "live on"; };
+  es.onerror = () => { live.className = "live off"; }; // EventSource переподключится сам
+  for (const type of ["set", "update"]) {
+    es.addEventListener(type, ev => onRecord(JSON.parse(ev.data)));
+  }
+  // сервер потерял часть событий — проще перечитать всё
+  es.addEventListener("reset", () => { loadMap(); loadTable(false); });
+}
+
+window.addEventListener("hashchange", route);
+loadMap();
+loadTable(false);
+setInterval(loadMap, 60000);
+connect();
+route();
@@ -0,0 +1,37 @@
+// Грубые контуры суши [lon, lat] для подложки карты — без внешних тайлов
+"use strict";
+const WORLD = [
+  // Северная Америка
+  [[-168,66],[-162,70],[-156,71.3],[-141,69.6],[-128,70],[-115,68.5],[-95,72],[-82,73],[-80,66],[-88,64],[-94,59],[-85,55],[-80,52],[-78,58],[-72,61],[-65,60],[-61,56],[-56,52],[-60,47],[-66,44.5],[-70,43],[-70,41.5],[-74,40.5],[-76,35],[-81,31],[-80,25.5],[-82,27],[-84,30],[-89,30],[-94,29.5],[-97,27],[-97.5,22],[-96,19],[-91,18.5],[-87,21.5],[-88,16],[-83.5,15],[-83.5,11],[-79.5,9],[-77.5,8.5],[-80,7.5],[-85,10],[-87.5,13],[-92,14.5],[-96,15.7],[-105.5,20],[-109,25.5],[-112.5,29.5],[-114.7,31.7],[-114,30],[-110,23],[-112,26],[-115,30],[-117,32.5],[-120.5,34.5],[-124,40],[-124.5,43],[-124,46.5],[-123,49],[-127,50.5],[-130,54.5],[-134,58],[-140,60],[-147,60.5],[-152,59],[-158,57],[-165,54.5],[-158,58.5],[-162,60],[-165,62.5],[-164,64.5]],
+  // Южная Америка
+  [[-77.5,8.5],[-75,11],[-72,12],[-64,10.5],[-60,8.5],[-55,6],[-51,4],[-50,0],[-44,-2.5],[-35,-5],[-35,-9],[-39,-14],[-39,-18],[-41,-22],[-45,-23.5],[-48.5,-26],[-53,-33.5],[-57.5,-35],[-58,-38.5],[-62,-39],[-65,-42],[-65,-47],[-69,-51],[-68.5,-53],[-71,-55],[-74.5,-52],[-75.5,-47],[-73.5,-42],[-73.5,-37],[-71.5,-30],[-70.3,-18.5],[-76,-14],[-81,-6],[-80,-1],[-80,2],[-77.5,4]],
+  // Африка
+  [[-17,21],[-13,27.5],[-9.5,30.5],[-6,35.8],[-1,35.3],[3,36.8],[10,37.3],[11,33.5],[15,32.3],[20,31],[20,32.8],[25,31.8],[32,31.3],[34.5,28],[37,22],[38.5,18],[43,12.5],[51,11.8],[51,10.5],[48,4.5],[42,-1],[40,-5],[40,-10.5],[40.5,-15],[35.5,-21],[35.5,-24],[32.8,-26],[30,-31.5],[25,-34],[20,-34.8],[18.4,-34],[17,-29],[14.5,-22.5],[11.8,-17],[13.5,-11],[12,-5],[9,-1],[9.5,3.8],[6,4.3],[1,6],[-4,5.2],[-7.5,4.3],[-11.5,7],[-13.5,9.5],[-16.5,12.5],[-17.5,14.7],[-16,17.5]],
+  // Евразия
+  [[-6,36],[-9,37],[-9.5,39],[-8.8,42],[-9.3,43],[-1.5,43.5],[-1.3,46],[-4.5,48],[-1.5,48.7],[1.5,50.5],[4,51.5],[5,53],[8.5,53.5],[8.3,55.5],[8.6,57.1],[10.5,57.7],[10.5,56],[12.5,54.5],[14,54],[19,54.5],[21,56],[21.5,57.5],[24,57.2],[24,59.3],[29.5,60],[23,60],[21.5,61],[21.5,63],[25,65],[22,65.8],[17.5,62.5],[19,60],[16.5,57],[14,55.5],[12.5,56.2],[11,58.8],[8,58],[5.5,58.8],[5,61],[7,62.5],[10.5,64],[14,66.5],[16,68.5],[19,69.8],[23,70.5],[28,71],[31,70],[33,69.2],[40,67.8],[41.5,66.5],[34.5,66],[37.5,64.5],[44,66],[44,68.5],[53.5,68.5],[58,68.8],[60,69.8],[68,69],[69,72.8],[73,72],[72.5,68.5],[75,72.5],[80,72.5],[87,74],[100,76.5],[105,77.5],[113,73.7],[128,72.8],[130,71],[140,72.5],[150,71.5],[160,69.8],[170,70],[180,69],[180,65],[178,64.5],[174,61.8],[170,60],[163,59.8],[162,57.5],[156.5,51],[156,57.5],[160,61.5],[154,59.3],[142,59.3],[135,54.5],[140.5,53.2],[141,48],[135,43.3],[132,43],[129.5,41],[129.5,36],[126.5,34.5],[126.5,37.5],[125,39.5],[121.5,39],[122,40.8],[119,39.2],[117.8,38.5],[119,37],[122.5,37],[120,35],[121.5,32],[122,29.5],[119.5,25.5],[116.5,23],[110.5,21],[108,21.5],[106.5,19.5],[108.8,15],[109,11.5],[105,8.6],[105,10.3],[103,10.5],[100.5,13.5],[99.2,10.5],[100.5,6.5],[103.5,1.4],[102,4],[100.5,7.5],[98.5,8.5],[98.3,12.5],[97.5,16.5],[94.5,16],[94,19],[92,21.5],[90,22],[86.5,20],[84.5,19],[80.3,15.5],[80,10.5],[77.5,8],[76,10],[74.5,14],[72.8,19],[72.6,21.5],[69,22.5],[67,24.8],[61.5,25.2],[57.5,25.7],[56.3,27],[51.5,27.9],[50,30],[48,29.8],[50,26],[51.5,25],[56,26.2],[56.5,24],[59.8,22.5],[57.8,19],[55,17],[52,15.8],[45,12.8],[43.5,12.7],[42.7,15.5],[39,21.5],[35,28],[34.5,29.5],[34.8,32.5],[35.9,35.5],[36,36.8],[32.5,36.1],[28,36.7],[26.5,38.5],[26.3,40.5],[24,40.8],[22.8,40.5],[24,38],[22.5,36.5],[21.2,37.8],[21,39.7],[19.5,41.8],[16,43.5],[13.6,45.7],[12.3,45.3],[14,42.5],[16,41.5],[18.5,40.2],[17,39],[16.5,38.1],[15.6,38],[16,39.5],[15,40.2],[12.5,41.5],[10.5,43],[8.8,44.4],[7,43.6],[4.5,43.4],[3.1,42.4],[3.2,41.9],[0.8,41],[-0.3,39.4],[0,38.7],[-0.8,37.6],[-2.2,36.7],[-4.5,36.6]],
+  // Австралия и Океания
+  [[113.5,-22],[114,-26.5],[115,-33.5],[117.5,-35],[123.5,-33.9],[129,-31.7],[131.5,-31.5],[135,-34.8],[138,-35.5],[140,-38],[144,-38.3],[146.5,-39],[150,-37.5],[151.3,-33.5],[153,-31],[153.5,-28],[153,-25],[150,-22],[146.5,-19],[145.3,-15],[143.5,-14],[142.5,-10.7],[141.5,-13],[141.6,-17],[139.5,-17.5],[136,-15],[136.7,-12.2],[133,-11.3],[130,-12.5],[129.5,-15],[126,-14],[122,-17.5],[121,-19.5],[117,-20.7]],
+  [[172.7,-34.5],[174.5,-36.8],[178.5,-37.7],[177,-39.3],[174.8,-41.3],[173,-40.2],[174.6,-36.9]],
+  [[172.7,-40.5],[174.2,-41.8],[173,-43.8],[171,-44.8],[169,-46.6],[166.5,-46],[168.3,-44]],
+  [[131,-1],[134,-1],[138,-1.7],[141,-2.6],[145.8,-4.8],[147.5,-6],[147.7,-8],[150,-10.5],[146,-8.5],[143.5,-9],[141,-9],[138,-8.3],[137.8,-5.3],[135,-4.4],[132.5,-4]],
+  // Юго-Восточная Азия
+  [[109,1.5],[110,-1.5],[111.7,-3],[116,-4],[116.5,-1],[119,1],[117.8,4],[119,5.2],[117,7],[116,6],[115,4.8],[112,2.8]],
+  [[95.3,5.5],[97.5,5.2],[100.5,2],[104,-1],[106,-3],[106,-6],[104.5,-5.8],[102,-4],[100,-1],[98.5,1.7]],
+  [[105.2,-6.8],[108,-6.3],[111,-6.5],[114.5,-7.7],[114.4,-8.6],[110,-8.1],[106.5,-7.4]],
+  [[120,18.5],[122.2,18.5],[122,16.5],[124,13],[121.8,13.8],[120.5,14.5],[120,16.5]],
+  [[130,31],[131.5,31.5],[132,33.8],[135,33.7],[136.8,34.3],[139.8,35],[140.8,35.7],[141,38.3],[142,39.8],[141.3,41.4],[140,40.5],[139.8,38.8],[138.5,37.3],[136.7,37],[135.5,35.6],[132.6,35.5],[130.8,34]],
+  [[140,41.5],[141.5,42.5],[143.5,42],[145.5,43.3],[145,44.2],[142,45.5],[141.5,43.4]],
+  // острова Атлантики
+  [[-5.7,50],[1.4,51.2],[1.7,52.7],[0,53.5],[-1.5,55],[-2,56],[-1.8,57.6],[-4,58.6],[-5,58.6],[-6.2,56.8],[-5,55.5],[-3,54.9],[-3.4,53.5],[-4.6,52.8],[-5,51.7],[-3.5,51.4]],
+  [[-6,52.2],[-6.2,53.9],[-5.8,55.2],[-8,55.2],[-10,54],[-10.2,51.8],[-8,51.6]],
+  [[-24,65.5],[-22,66.4],[-16,66.5],[-13.5,65.2],[-15,64.2],[-19,63.4],[-22.5,63.8]],
+  [[-85,21.9],[-82,23.1],[-77,22],[-74.2,20.2],[-77.5,19.9],[-80,21.7]],
+  [[44,-25],[47,-25],[50.5,-15.5],[49.5,-12],[48,-14],[44,-17]],
+  // Арктика
+  [[-73,78],[-60,82],[-30,83.5],[-20,82],[-18,77],[-22,70.5],[-26,68.5],[-33,68],[-40,65],[-43,60],[-48,61],[-52,64],[-54,67],[-52,70],[-56,74],[-66,76]],
+  [[-80,73.7],[-68,70.5],[-62,66.8],[-64,63.5],[-72,62.5],[-78,64.5],[-73,68],[-80,70],[-90,72]],
+  [[-118,73],[-105,73],[-100,70],[-110,68.6],[-118,70]],
+  [[-90,76],[-75,78],[-63,82],[-80,83],[-95,81]],
+  // Антарктида
+  [[-180,-84],[-180,-78],[-160,-78],[-150,-76],[-135,-74.5],[-120,-73.8],[-100,-73],[-80,-73],[-75,-71],[-62,-64.5],[-57,-63.3],[-60,-66],[-62,-70],[-60,-75],[-45,-78],[-35,-77.7],[-26,-75],[-15,-72],[0,-70],[20,-70],[40,-69],[55,-66.5],[70,-68],[80,-67],[100,-66],[120,-66.5],[140,-66.5],[160,-70],[170,-72],[165,-78],[180,-78],[180,-84]],
+];
@@ -0,0 +1,21 @@
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// static — дашборд целиком, без внешних CDN: карта рисуется на canvas
+// по встроенным контурам материков, данные берутся из /v1 API
+//
+//go:embed static
+var static embed.FS
+
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
@@ -0,0 +1,104 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+// Breaker — простой circuit breaker: после threshold ошибок подряд открывается
+// на Cooldown, затем пропускает один пробный запрос (half-open)
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	state     State
+	fails     int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, state: StateClosed}
+}
+
+func (b *Breaker) Allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	b.state = StateClosed
+	b.fails = 0
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	b.fails++
+	b.probing = false
+	if b.state == StateHalfOpen || (b.threshold > 0 && b.fails >= b.threshold) {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Cancel — запрос прерван вызывающим: исход не засчитывается, но пробный
+// слот half-open освобождается
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+type BreakerStatus struct {
+	State     State      `json:"state"`
+	Failures  int        `json:"consecutive_failures"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{State: b.state, Failures: b.fails, LastError: b.lastError}
+	if b.state != StateClosed {
+		t := b.openedAt
+		st.OpenedAt = &t
+	}
+	return st
+}
@@ -0,0 +1,264 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit open")
+	ErrRateLimited = errors.New("rate limited")
+)
+
+type Config struct {
+	Timeout         time.Duration
+	Retries         int
+	RetryBase       time.Duration
+	RetryMax        time.Duration
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	Proxy           string
+	// RateLimit — запросов в секунду, 0 — без ограничения. Запрос сверх
+	// лимита сразу получает ErrRateLimited и не учитывается брейкером.
+	RateLimit float64
+	RateBurst int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:         5 * time.Second,
+		Retries:         2,
+		RetryBase:       200 * time.Millisecond,
+		RetryMax:        2 * time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+	}
+}
+
+// общий транспорт, чтобы все апстримы переиспользовали соединения
+var (
+	transportMu sync.Mutex
+	transports  = map[string]*http.Transport{}
+)
+
+func transportFor(proxy string) (*http.Transport, error) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if t, ok := transports[proxy]; ok {
+		return t, nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = 16
+	t.DialContext = (&net.Dialer{Timeout: 3 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %q: %w", proxy, err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	transports[proxy] = t
+	return t, nil
+}
+
+type Client struct {
+	name     string
+	cfg      Config
+	http     *http.Client
+	breaker  *Breaker
+	limiter  *limiter
+	optional bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*Client{}
+)
+
+// New создаёт клиент апстрима и регистрирует его брейкер для health
+func New(name string, cfg Config) (*Client, error) {
+	return newClient(name, cfg, false)
+}
+
+// NewOptional — клиент, чей открытый брейкер виден в health, но не делает
+// сервис degraded: вебхуки, справочные запросы API
+func NewOptional(name string, cfg Config) (*Client, error) {
+	return newClient(name, cfg, true)
+}
+
+func newClient(name string, cfg Config, optional bool) (*Client, error) {
+	t, err := transportFor(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		name:     name,
+		cfg:      cfg,
+		http:     &http.Client{Transport: t, Timeout: cfg.Timeout},
+		breaker:  NewBreaker(cfg.BreakerFailures, cfg.BreakerCooldown),
+		limiter:  newLimiter(cfg.RateLimit, cfg.RateBurst),
+		optional: optional,
+	}
+	registryMu.Lock()
+	registry[name] = c
+	registryMu.Unlock()
+	return c, nil
+}
+
+func MustNew(name string, cfg Config) *Client {
+	c, err := New(name, cfg)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func MustNewOptional(name string, cfg Config) *Client {
+	c, err := NewOptional(name, cfg)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *Client) Name() string { return c.name }
+
+// Do выполняет запрос. Автоматически повторяются только GET и HEAD:
+// повтор POST после таймаута или 5xx может задублировать действие
+// на стороне апстрима (измерение Globalping, списание кредитов).
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.do(req, req.Method == http.MethodGet || req.Method == http.MethodHead)
+}
+
+// DoRetry повторяет запрос любого метода — для получателей, которые сами
+// дедуплицируют доставку (вебхуки с X-RTT-Delivery)
+func (c *Client) DoRetry(req *http.Request) (*http.Response, error) {
+	return c.do(req, true)
+}
+
+// do повторяет сетевые ошибки, 429 и 5xx; запрос с телом повторяется только
+// если тело можно перечитать (GetBody). Брейкер видит один исход на вызов.
+// При неуспехе последней попытки с ответом возвращается сам ответ.
+func (c *Client) do(req *http.Request, retry bool) (*http.Response, error) {
+	ctx := req.Context()
+	if !c.limiter.allow() {
+		return nil, fmt.Errorf("%s: %w", c.name, ErrRateLimited)
+	}
+	if !c.breaker.Allow() {
+		return nil, fmt.Errorf("%s: %w", c.name, ErrCircuitOpen)
+	}
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 {
+			r = req.Clone(ctx)
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					c.breaker.Cancel()
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+		resp, err := c.http.Do(r)
+		// отмена или дедлайн вызывающего — не отказ апстрима
+		if err != nil && ctx.Err() != nil {
+			c.breaker.Cancel()
+			return nil, ctx.Err()
+		}
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable {
+			c.breaker.Success()
+			return resp, nil
+		}
+
+		canRetry := retry && attempt < c.cfg.Retries && ctx.Err() == nil &&
+			(req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+		var wait time.Duration
+		if canRetry {
+			wait = c.backoff(attempt)
+			if d, ok := retryAfter(resp); ok {
+				wait = d
+			}
+			// Retry-After дальше дедлайна — ждать бессмысленно
+			if dl, ok := ctx.Deadline(); ok && time.Until(dl) < wait {
+				canRetry = false
+			}
+		}
+		if !canRetry {
+			if err != nil {
+				c.breaker.Failure(err)
+			} else {
+				c.breaker.Failure(fmt.Errorf("http %d", resp.StatusCode))
+			}
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			c.breaker.Cancel()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryAfter разбирает Retry-After: секунды или HTTP-дата
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(n)*time.Second, 0), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// backoff — full jitter: случайно в [0, min(RetryMax, RetryBase*2^attempt))
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.RetryBase << attempt
+	if d <= 0 || (c.cfg.RetryMax > 0 && d > c.cfg.RetryMax) {
+		d = c.cfg.RetryMax
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)))
+}
+
+func (c *Client) Breaker() BreakerStatus { return c.breaker.Status() }
+
+type Health struct {
+	Name     string        `json:"name"`
+	Optional bool          `json:"optional,omitempty"`
+	Breaker  BreakerStatus `json:"breaker"`
+}
+
+func HealthAll() []Health {
+	registryMu.RLock()
+	out := make([]Health, 0, len(registry))
+	for name, c := range registry {
+		out = append(out, Health{Name: name, Optional: c.optional, Breaker: c.breaker.Status()})
+	}
+	registryMu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -2,6 +2,15 @@ package utils
 
 import "math"
 
+// координаты сервера, до которого меряем RTT
+const (
+	ServerLat = 36.102
+	ServerLon = -115.1447
+)
+
+// SpeedOfLightKmPerMs — скорость света в вакууме, км за миллисекунду
+const SpeedOfLightKmPerMs = 299.792458
+
 func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	const R = 6371.0
 	LatServ, LonServ := deg2rad(lat1), deg2rad(lon1)
@@ -18,3 +27,20 @@ func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
 }
 
 func deg2rad(d float64) float64 { return d * math.Pi / 180 }
+
+// MinRTTms — минимально возможный RTT (туда и обратно) на distanceKm по
+// волокну, где свет идёт со скоростью fiberFactor*c
+func MinRTTms(distanceKm, fiberFactor float64) float64 {
+	if fiberFactor <= 0 {
+		return 0
+	}
+	return 2 * distanceKm / (SpeedOfLightKmPerMs * fiberFactor)
+}
+
+// MaxDistanceKm — дальше этого клиент с таким RTT быть не может
+func MaxDistanceKm(rttMs, fiberFactor float64) float64 {
+	if rttMs <= 0 || fiberFactor <= 0 {
+		return 0
+	}
+	return rttMs / 2 * SpeedOfLightKmPerMs * fiberFactor
+}
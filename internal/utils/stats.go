@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+func Median(xs []float64) float64 {
+	return Quantile(xs, 0.5)
+}
+
+// Quantile — линейная интерполяция между соседними значениями, xs не меняется
+func Quantile(xs []float64, q float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	if q <= 0 {
+		return s[0]
+	}
+	if q >= 1 {
+		return s[len(s)-1]
+	}
+	pos := q * float64(len(s)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return s[lo] + (s[hi]-s[lo])*(pos-float64(lo))
+}
+
+// MAD — median absolute deviation
+func MAD(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	m := Median(xs)
+	dev := make([]float64, len(xs))
+	for i, x := range xs {
+		dev[i] = math.Abs(x - m)
+	}
+	return Median(dev)
+}
+
+func WeightedMedian(xs, ws []float64) float64 {
+	if len(xs) == 0 || len(xs) != len(ws) {
+		return Median(xs)
+	}
+	idx := make([]int, len(xs))
+	var total float64
+	for i := range idx {
+		idx[i] = i
+		total += ws[i]
+	}
+	if total <= 0 {
+		return Median(xs)
+	}
+	sort.Slice(idx, func(a, b int) bool { return xs[idx[a]] < xs[idx[b]] })
+	var acc float64
+	for _, i := range idx {
+		acc += ws[i]
+		if acc >= total/2 {
+			return xs[i]
+		}
+	}
+	return xs[idx[len(idx)-1]]
+}
@@ -0,0 +1,108 @@
+package vpnscore
+
+import (
+	"RTTServer/internal/enrich"
+	"RTTServer/internal/model"
+	"RTTServer/internal/utils"
+	"context"
+	"fmt"
+	"math"
+)
+
+const Name = model.StageVPN
+
+const (
+	VerdictConsistent = "consistent"
+	VerdictSuspicious = "suspicious"
+	VerdictLikelyVPN  = "likely_vpn"
+)
+
+type Config struct {
+	FiberFactor float64
+
+	// RTT клиента больше базового Globalping во столько раз
+	BaselineRatio float64
+	// и не меньше чем на столько миллисекунд (чтобы не шуметь на малых RTT)
+	BaselineExcessMs float64
+	// вклад правил в score
+	BaselineWeight   float64
+	ImpossibleWeight float64
+
+	SuspiciousScore float64
+	VPNScore        float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		FiberFactor:      0.67,
+		BaselineRatio:    2,
+		BaselineExcessMs: 30,
+		BaselineWeight:   0.5,
+		ImpossibleWeight: 0.8,
+		SuspiciousScore:  0.4,
+		VPNScore:         0.7,
+	}
+}
+
+// Enricher сравнивает tcpi_rtt клиента с базовым RTT Globalping для его
+// геолокации и с физическим минимумом для DistanceToServer. Если globalping
+// есть в конвейере, идёт после него.
+type Enricher struct {
+	Cfg Config
+}
+
+func (e *Enricher) Name() string           { return Name }
+func (e *Enricher) Dependencies() []string { return []string{model.StageGeo} }
+
+// SoftDependencies: базовая линия необязательна, но после успешного повтора
+// globalping вердикт пересчитывается
+func (e *Enricher) SoftDependencies() []string { return []string{model.StageGlobalping} }
+
+func (e *Enricher) Enrich(_ context.Context, rec *model.RTTRecord) error {
+	if rec.RTT_ms <= 0 {
+		return enrich.Skip("no tcp rtt")
+	}
+	if rec.DistanceToServer == nil && rec.GlobalpingRTT <= 0 {
+		return enrich.Skip("no baseline and no distance")
+	}
+	rec.VPN = Score(e.Cfg, rec.RTT_ms, rec.GlobalpingRTT, rec.GlobalpingConfidence, rec.DistanceToServer)
+	return nil
+}
+
+// Score: baselineMs <= 0 или distanceKm == nil — соответствующее правило не применяется
+func Score(cfg Config, rttMs, baselineMs, baselineConfidence float64, distanceKm *float64) *model.VPNVerdict {
+	v := &model.VPNVerdict{}
+
+	if baselineMs > 0 {
+		ratio := rttMs / baselineMs
+		if ratio >= cfg.BaselineRatio && rttMs-baselineMs >= cfg.BaselineExcessMs {
+			// чем увереннее базовая линия, тем больше вес
+			w := cfg.BaselineWeight
+			if baselineConfidence > 0 {
+				w *= 0.5 + 0.5*baselineConfidence
+			}
+			w *= math.Min(ratio/cfg.BaselineRatio, 2)
+			v.Score += w
+			v.Reasons = append(v.Reasons, fmt.Sprintf("rtt %.1fms is %.1fx globalping baseline %.1fms", rttMs, ratio, baselineMs))
+		}
+	}
+
+	if distanceKm != nil {
+		minRTT := utils.MinRTTms(*distanceKm, cfg.FiberFactor)
+		if rttMs < minRTT {
+			v.Score += cfg.ImpossibleWeight
+			v.Reasons = append(v.Reasons, fmt.Sprintf("rtt %.1fms below physical minimum %.1fms for %.0fkm", rttMs, minRTT, *distanceKm))
+		}
+	}
+
+	v.Score = math.Round(math.Min(v.Score, 1)*1000) / 1000
+	switch {
+	case v.Score >= cfg.VPNScore:
+		v.Verdict = VerdictLikelyVPN
+	case v.Score >= cfg.SuspiciousScore:
+		v.Verdict = VerdictSuspicious
+	default:
+		v.Verdict = VerdictConsistent
+	}
+	return v
+}
@@ -0,0 +1,330 @@
+package webhook
+
+import (
+	"RTTServer/internal/model"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter — условие на запись, например:
+//
+//	asn == 13335 && rtt_ms > 150
+//	vpn_verdict != "consistent" || reputation contains "tor"
+//
+// Операторы: == != < <= > >= contains, связки && || ! и скобки.
+type Filter struct {
+	src  string
+	root node
+}
+
+func (f *Filter) String() string { return f.src }
+
+func (f *Filter) Match(rec model.RTTRecord) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+	return f.root.eval(rec)
+}
+
+type node interface {
+	eval(model.RTTRecord) bool
+}
+
+type orNode struct{ l, r node }
+type andNode struct{ l, r node }
+type notNode struct{ x node }
+type cmpNode struct {
+	field string
+	op    string
+	num   float64
+	str   string
+	isNum bool
+}
+
+func (n orNode) eval(r model.RTTRecord) bool  { return n.l.eval(r) || n.r.eval(r) }
+func (n andNode) eval(r model.RTTRecord) bool { return n.l.eval(r) && n.r.eval(r) }
+func (n notNode) eval(r model.RTTRecord) bool { return !n.x.eval(r) }
+
+func (n cmpNode) eval(r model.RTTRecord) bool {
+	v, ok := fieldValue(r, n.field)
+	if !ok {
+		return false
+	}
+	switch v := v.(type) {
+	case []string:
+		if n.op == "contains" {
+			return slices.Contains(v, n.str)
+		}
+		return false
+	case float64:
+		if !n.isNum {
+			return false
+		}
+		switch n.op {
+		case "==":
+			return v == n.num
+		case "!=":
+			return v != n.num
+		case "<":
+			return v < n.num
+		case "<=":
+			return v <= n.num
+		case ">":
+			return v > n.num
+		case ">=":
+			return v >= n.num
+		}
+	case string:
+		switch n.op {
+		case "==":
+			return strings.EqualFold(v, n.str)
+		case "!=":
+			return !strings.EqualFold(v, n.str)
+		case "contains":
+			return strings.Contains(strings.ToLower(v), strings.ToLower(n.str))
+		}
+	}
+	return false
+}
+
+// Fields — имена полей, доступных в фильтре
+var Fields = []string{
+	"ip", "listener_port", "rtt_ms", "rttvar_ms", "country", "region", "city",
+	"distance_km", "asn", "as_org", "network_type", "globalping_rtt_ms",
+	"vpn_score", "vpn_verdict", "reputation", "feasible",
+}
+
+func fieldValue(r model.RTTRecord, field string) (any, bool) {
+	geo := func(f func(*model.Geo) string) (any, bool) {
+		if r.Geo == nil {
+			return nil, false
+		}
+		return f(r.Geo), true
+	}
+	switch field {
+	case "ip":
+		return r.IP, true
+	case "listener_port":
+		return float64(r.ListenerPort), true
+	case "rtt_ms":
+		return r.RTT_ms, true
+	case "rttvar_ms":
+		return r.RTTVar_ms, true
+	case "country":
+		return geo(func(g *model.Geo) string { return g.Country })
+	case "region":
+		return geo(func(g *model.Geo) string { return g.Region })
+	case "city":
+		return geo(func(g *model.Geo) string { return g.City })
+	case "distance_km":
+		if r.DistanceToServer == nil {
+			return nil, false
+		}
+		return *r.DistanceToServer, true
+	case "asn":
+		return float64(r.ASN), r.ASN != 0
+	case "as_org":
+		return r.ASOrg, r.ASOrg != ""
+	case "network_type":
+		return r.NetworkType, r.NetworkType != ""
+	case "globalping_rtt_ms":
+		return r.GlobalpingRTT, r.GlobalpingRTT > 0
+	case "vpn_score":
+		if r.VPN == nil {
+			return nil, false
+		}
+		return r.VPN.Score, true
+	case "vpn_verdict":
+		if r.VPN == nil {
+			return nil, false
+		}
+		return r.VPN.Verdict, true
+	case "reputation":
+		return r.Reputation, true
+	case "feasible":
+		if r.Feasibility == nil {
+			return nil, false
+		}
+		return strconv.FormatBool(r.Feasibility.Feasible), true
+	}
+	return nil, false
+}
+
+// ParseFilter; пустая строка — фильтр, пропускающий всё
+func ParseFilter(src string) (*Filter, error) {
+	f := &Filter{src: src}
+	if strings.TrimSpace(src) == "" {
+		return f, nil
+	}
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	f.root, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("filter: unexpected %q", p.toks[p.pos].text)
+	}
+	return f, nil
+}
+
+type token struct {
+	kind byte // i — имя, n — число, s — строка, o — оператор
+	text string
+}
+
+func tokenize(src string) ([]token, error) {
+	var out []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(rs) && rs[j] != c {
+				j++
+			}
+			if j == len(rs) {
+				return nil, fmt.Errorf("filter: unterminated string")
+			}
+			out = append(out, token{'s', string(rs[i+1 : j])})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			out = append(out, token{'n', string(rs[i:j])})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			out = append(out, token{'i', string(rs[i:j])})
+			i = j
+		default:
+			op := ""
+			for _, cand := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(string(rs[i:]), cand) {
+					op = cand
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("filter: unexpected %q", string(c))
+			}
+			out = append(out, token{'o', op})
+			i += len([]rune(op))
+		}
+	}
+	return out, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek(text string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == 'o' && p.toks[p.pos].text == text
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("||") {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("&&") {
+		p.pos++
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch {
+	case p.peek("!"):
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	case p.peek("("):
+		p.pos++
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("filter: missing )")
+		}
+		p.pos++
+		return x, nil
+	}
+	return p.parseCmp()
+}
+
+func (p *parser) parseCmp() (node, error) {
+	if p.pos+2 >= len(p.toks) {
+		return nil, fmt.Errorf("filter: incomplete comparison")
+	}
+	field, op, val := p.toks[p.pos], p.toks[p.pos+1], p.toks[p.pos+2]
+	if field.kind != 'i' || !slices.Contains(Fields, field.text) {
+		return nil, fmt.Errorf("filter: unknown field %q", field.text)
+	}
+	opText := op.text
+	if !(op.kind == 'o' && slices.Contains([]string{"==", "!=", "<", "<=", ">", ">="}, opText)) &&
+		!(op.kind == 'i' && opText == "contains") {
+		return nil, fmt.Errorf("filter: bad operator %q", opText)
+	}
+	n := cmpNode{field: field.text, op: opText}
+	switch val.kind {
+	case 'n':
+		f, err := strconv.ParseFloat(val.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter: bad number %q", val.text)
+		}
+		n.num, n.isNum, n.str = f, true, val.text
+	case 's':
+		n.str = val.text
+	case 'i':
+		if val.text != "true" && val.text != "false" {
+			return nil, fmt.Errorf("filter: unexpected %q, quote strings", val.text)
+		}
+		n.str = val.text
+	default:
+		return nil, fmt.Errorf("filter: unexpected %q", val.text)
+	}
+	p.pos += 3
+	return n, nil
+}
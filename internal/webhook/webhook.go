@@ -0,0 +1,408 @@
+package webhook
+
+import (
+	"RTTServer/internal/cache"
+	"RTTServer/internal/model"
+	"RTTServer/internal/upstream"
+	"RTTServer/internal/vpnscore"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// типы событий, на которые можно подписаться
+const (
+	EventNewIP       = "ip.new"             // первая завершённая запись для нового ip
+	EventMeasurement = "measurement"        // каждое завершённое измерение
+	EventEnrichment  = "enrichment.updated" // дообогащение записи после ретрая
+	EventVPN         = "vpn.flagged"        // вердикт VPN не consistent
+)
+
+var EventTypes = []string{EventNewIP, EventMeasurement, EventEnrichment, EventVPN}
+
+// Subscription — одна подписка из файла RTT_WEBHOOKS_FILE (JSON-массив)
+type Subscription struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // пусто — все типы
+	Filter string   `json:"filter,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+func Load(path string) ([]Subscription, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var subs []Subscription
+	if err := json.Unmarshal(b, &subs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return subs, nil
+}
+
+// Delivery — запись журнала доставок
+type Delivery struct {
+	ID           uint64    `json:"id"`
+	Subscription string    `json:"subscription"`
+	Event        string    `json:"event"`
+	EventID      uint64    `json:"event_id"`
+	IP           string    `json:"ip"`
+	At           time.Time `json:"at"`
+	DurationMs   float64   `json:"duration_ms"`
+	Status       int       `json:"status,omitempty"`
+	OK           bool      `json:"ok"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// SubscriptionInfo — подписка без секрета для /webhooks
+type SubscriptionInfo struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Filter    string   `json:"filter,omitempty"`
+	Signed    bool     `json:"signed"`
+	Queued    int      `json:"queued"`
+	Delivered uint64   `json:"delivered"`
+	Failed    uint64   `json:"failed"`
+	Dropped   uint64   `json:"dropped"`
+}
+
+// Payload — тело POST на url подписки
+type Payload struct {
+	Delivery uint64          `json:"delivery"`
+	Type     string          `json:"type"`
+	EventID  uint64          `json:"event_id"`
+	At       time.Time       `json:"at"`
+	Record   model.RTTRecord `json:"record"`
+}
+
+const (
+	queueSize = 256
+	logSize   = 500
+)
+
+type job struct {
+	id   uint64
+	typ  string
+	ev   cache.Event
+	body []byte
+}
+
+type sub struct {
+	Subscription
+	filter *Filter
+	client *upstream.Client
+	queue  chan job
+
+	mu                         sync.Mutex
+	delivered, failed, dropped uint64
+}
+
+// Dispatcher читает события хранилища и рассылает их подписчикам.
+// У каждой подписки своя очередь и свой воркер, так что медленный
+// получатель не задерживает остальных; при переполнении очереди
+// событие отбрасывается и попадает в журнал.
+type Dispatcher struct {
+	subs []*sub
+
+	mu     sync.Mutex
+	nextID uint64
+	log    []Delivery
+}
+
+// New проверяет подписки; каждой достаётся свой upstream-клиент
+// "webhook:<id>" с ретраями и circuit breaker'ом
+func New(subs []Subscription, cfg upstream.Config) (*Dispatcher, error) {
+	d := &Dispatcher{nextID: 1}
+	seen := map[string]bool{}
+	for i, s := range subs {
+		if s.ID == "" {
+			s.ID = "hook-" + strconv.Itoa(i+1)
+		}
+		if seen[s.ID] {
+			return nil, fmt.Errorf("webhook %s: duplicate id", s.ID)
+		}
+		seen[s.ID] = true
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %s: bad url %q", s.ID, s.URL)
+		}
+		if len(s.Events) == 0 {
+			s.Events = EventTypes
+		}
+		for _, e := range s.Events {
+			if !slices.Contains(EventTypes, e) {
+				return nil, fmt.Errorf("webhook %s: unknown event %q", s.ID, e)
+			}
+		}
+		f, err := ParseFilter(s.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", s.ID, err)
+		}
+		// недоступный получатель не должен делать /health degraded
+		c, err := upstream.NewOptional("webhook:"+s.ID, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", s.ID, err)
+		}
+		d.subs = append(d.subs, &sub{Subscription: s, filter: f, client: c, queue: make(chan job, queueSize)})
+	}
+	return d, nil
+}
+
+func (d *Dispatcher) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.subs)
+}
+
+// Run подписывается на события хранилища; при отключении медленного
+// подписчика переподключается с последнего обработанного ID
+func (d *Dispatcher) Run(store *cache.Store) {
+	for _, s := range d.subs {
+		go d.worker(s)
+	}
+	// ip, для которых пришёл Set с Created, но обогащение ещё идёт
+	awaiting := map[string]time.Time{}
+	// ip, чей последний Set ещё с pending-этапами и не отдан подписчикам
+	withheld := map[string]time.Time{}
+	// запись, не дождавшаяся завершения, протухает в store за cache.TTL —
+	// тогда и её отметки больше не нужны
+	prune := time.NewTicker(time.Minute)
+	defer prune.Stop()
+	var last uint64
+	for {
+		replay, ch, complete, cancel := store.Subscribe(last)
+		if !complete {
+			log.Printf("webhook: missed events after %d", last)
+		}
+		for _, ev := range replay {
+			d.handle(ev, awaiting, withheld)
+			last = ev.ID
+		}
+	recv:
+		for {
+			select {
+			case ev, ok := <-ch:
+				if !ok {
+					break recv
+				}
+				d.handle(ev, awaiting, withheld)
+				last = ev.ID
+			case now := <-prune.C:
+				expire(awaiting, now)
+				expire(withheld, now)
+			}
+		}
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func expire(m map[string]time.Time, now time.Time) {
+	for ip, at := range m {
+		if now.Sub(at) > cache.TTL {
+			delete(m, ip)
+		}
+	}
+}
+
+// handle превращает событие хранилища в события вебхуков
+func (d *Dispatcher) handle(ev cache.Event, awaiting, withheld map[string]time.Time) {
+	rec := ev.Record
+	var types []string
+	switch ev.Type {
+	case cache.EventSet:
+		if ev.Created {
+			awaiting[rec.IP] = ev.At
+		}
+		// HandleConn кладёт запись дважды: сразу после замера с pending-этапами
+		// и после конвейера; наружу уходит только завершённая
+		if pending(rec) {
+			withheld[rec.IP] = ev.At
+			return
+		}
+		delete(withheld, rec.IP)
+		types = measured(rec, awaiting)
+	case cache.EventUpdate:
+		// фоновые этапы (rdns) завершают замер уже через Update
+		if _, ok := withheld[rec.IP]; ok {
+			if pending(rec) {
+				return
+			}
+			delete(withheld, rec.IP)
+			types = measured(rec, awaiting)
+			break
+		}
+		types = append(types, EventEnrichment)
+	}
+	for _, typ := range types {
+		for _, s := range d.subs {
+			if !slices.Contains(s.Events, typ) || !s.filter.Match(rec) {
+				continue
+			}
+			d.enqueue(s, typ, ev)
+		}
+	}
+}
+
+// measured — события завершённого замера
+func measured(rec model.RTTRecord, awaiting map[string]time.Time) []string {
+	var types []string
+	if _, ok := awaiting[rec.IP]; ok {
+		delete(awaiting, rec.IP)
+		types = append(types, EventNewIP)
+	}
+	types = append(types, EventMeasurement)
+	if rec.VPN != nil && rec.VPN.Verdict != vpnscore.VerdictConsistent {
+		types = append(types, EventVPN)
+	}
+	return types
+}
+
+func pending(rec model.RTTRecord) bool {
+	for _, st := range rec.Enrichment {
+		if st.Status == model.StatusPending {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) enqueue(s *sub, typ string, ev cache.Event) {
+	d.mu.Lock()
+	id := d.nextID
+	d.nextID++
+	d.mu.Unlock()
+	body, err := json.Marshal(Payload{Delivery: id, Type: typ, EventID: ev.ID, At: ev.At, Record: ev.Record})
+	if err != nil {
+		log.Printf("webhook %s: marshal: %v", s.ID, err)
+		return
+	}
+	select {
+	case s.queue <- job{id: id, typ: typ, ev: ev, body: body}:
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+		d.record(Delivery{ID: id, Subscription: s.ID, Event: typ, EventID: ev.ID, IP: ev.Record.IP,
+			At: time.Now(), Error: "queue full, dropped"})
+	}
+}
+
+func (d *Dispatcher) worker(s *sub) {
+	for j := range s.queue {
+		start := time.Now()
+		status, err := s.deliver(j)
+		dl := Delivery{ID: j.id, Subscription: s.ID, Event: j.typ, EventID: j.ev.ID, IP: j.ev.Record.IP,
+			At: start, DurationMs: float64(time.Since(start).Microseconds()) / 1000, Status: status}
+		if err == nil && status/100 != 2 {
+			err = fmt.Errorf("http %d", status)
+		}
+		s.mu.Lock()
+		if err != nil {
+			dl.Error = err.Error()
+			s.failed++
+		} else {
+			dl.OK = true
+			s.delivered++
+		}
+		s.mu.Unlock()
+		if err != nil {
+			log.Printf("webhook %s: delivery %d (%s %s): %v", s.ID, j.id, j.typ, j.ev.Record.IP, err)
+		}
+		d.record(dl)
+	}
+}
+
+// Sign — подпись тела: hex(HMAC-SHA256(secret, timestamp + "." + body))
+func Sign(secret, timestamp string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(timestamp))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func (s *sub) deliver(j job) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	// bytes.Reader даёт GetBody, поэтому upstream может повторить запрос;
+	// получатель дедуплицирует повторы по X-RTT-Delivery
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "RTTServer-webhook")
+	req.Header.Set("X-RTT-Event", j.typ)
+	req.Header.Set("X-RTT-Delivery", strconv.FormatUint(j.id, 10))
+	req.Header.Set("X-RTT-Timestamp", ts)
+	if s.Secret != "" {
+		req.Header.Set("X-RTT-Signature", "sha256="+Sign(s.Secret, ts, j.body))
+	}
+	resp, err := s.client.DoRetry(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(dl Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.log) == logSize {
+		copy(d.log, d.log[1:])
+		d.log = d.log[:logSize-1]
+	}
+	d.log = append(d.log, dl)
+}
+
+// Deliveries — журнал, новые первыми; subscription == "" — все подписки
+func (d *Dispatcher) Deliveries(subscription string, limit int) []Delivery {
+	out := []Delivery{}
+	if d == nil {
+		return out
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := len(d.log) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if subscription == "" || d.log[i].Subscription == subscription {
+			out = append(out, d.log[i])
+		}
+	}
+	return out
+}
+
+func (d *Dispatcher) Subscriptions() []SubscriptionInfo {
+	out := []SubscriptionInfo{}
+	if d == nil {
+		return out
+	}
+	for _, s := range d.subs {
+		s.mu.Lock()
+		out = append(out, SubscriptionInfo{
+			ID: s.ID, URL: s.URL, Events: s.Events, Filter: s.Filter, Signed: s.Secret != "",
+			Queued: len(s.queue), Delivered: s.delivered, Failed: s.failed, Dropped: s.dropped,
+		})
+		s.mu.Unlock()
+	}
+	return out
+}